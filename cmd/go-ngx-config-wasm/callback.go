@@ -5,7 +5,7 @@ import (
 	"errors"
 	"syscall/js"
 
-	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/pkg/crossplane"
 	"github.com/adityals/go-ngx-config/pkg/matcher"
 	"github.com/adityals/go-ngx-config/pkg/parser"
 )
@@ -77,6 +77,73 @@ func parseConfigWrapper() js.Func {
 	})
 }
 
+func parseFiles(files map[string]string, entry string, opts parseOptsConf) (*crossplane.Payload, error) {
+	// the includes are read from the given files, so errors are kept in the
+	// payload instead of failing the whole tree
+	parsed, err := parser.NewNgxConfFilesParser(files, entry, &crossplane.ParseOptions{
+		SkipDirectiveContextCheck: opts.SkipCtx,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+func getFiles(filesArg js.Value) map[string]string {
+	files := map[string]string{}
+
+	keys := js.Global().Get("Object").Call("keys", filesArg)
+	for i := 0; i < keys.Length(); i++ {
+		path := keys.Index(i).String()
+		files[path] = filesArg.Get(path).String()
+	}
+
+	return files
+}
+
+func parseFilesWrapper() js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) != 3 {
+			return errors.New("invalid arguments")
+		}
+
+		files := getFiles(args[0])
+		entry := args[1].String()
+		parseOpts := getParseOptsConf(args[2])
+
+		handler := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			resolve := args[0]
+			reject := args[1]
+
+			go func() {
+				ast, err := parseFiles(files, entry, parseOpts)
+				if err != nil {
+					errorConstructor := js.Global().Get("Error")
+					errorObject := errorConstructor.New(err.Error())
+					reject.Invoke(errorObject)
+					return
+				}
+
+				ast_json, err := json.MarshalIndent(ast, "", "  ")
+				if err != nil {
+					errorConstructor := js.Global().Get("Error")
+					errorObject := errorConstructor.New(err.Error())
+					reject.Invoke(errorObject)
+					return
+				}
+
+				resolve.Invoke(string(ast_json))
+			}()
+
+			return nil
+		})
+
+		promiseConstructor := js.Global().Get("Promise")
+		return promiseConstructor.New(handler)
+	})
+}
+
 func testLocation() js.Func {
 	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		if len(args) != 3 {
@@ -17,5 +17,6 @@ func main() {
 
 func registerCallbacks() {
 	js.Global().Set("goNgxParseConfig", parseConfigWrapper())
+	js.Global().Set("goNgxParseFiles", parseFilesWrapper())
 	js.Global().Set("goNgxTestLocation", testLocation())
 }
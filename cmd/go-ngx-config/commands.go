@@ -24,6 +24,8 @@ func NewParseCommand() *cobra.Command {
 	parseCmd.Flags().StringP("file", "f", "", "nginx.conf file location")
 	parseCmd.Flags().BoolP("single", "s", false, "parse single file or not")
 	parseCmd.Flags().StringP("output", "o", "", "output file location")
+	parseCmd.Flags().String("format", "json", "output format: json or sarif for the parse errors only")
+	parseCmd.Flags().Bool("from-dump", false, "the file is the output of nginx -T, - reads it from stdin")
 
 	return parseCmd
 }
@@ -37,7 +39,75 @@ func NewLocationTesterCommand() *cobra.Command {
 
 	testCmd.Flags().StringP("file", "f", "", "nginx.conf file location")
 	testCmd.Flags().BoolP("single", "s", false, "parse single file or not")
-	testCmd.Flags().StringP("url", "u", "", "target url")
+	testCmd.Flags().StringP("url", "u", "", "target url, e.g: http://localhost:80/my-location")
+	testCmd.Flags().StringSlice("files", []string{}, "files that exist when evaluating try_files")
+	testCmd.Flags().Bool("effective", false, "print the effective directives of the match with their level")
 
 	return testCmd
 }
+
+func NewFormatCommand() *cobra.Command {
+	fmtCmd := &cobra.Command{
+		Use:   "fmt",
+		Short: "A nginx config formatter",
+		RunE:  RunFormatNgx,
+		// a failed --check is not a usage error
+		SilenceUsage: true,
+	}
+
+	fmtCmd.Flags().StringP("file", "f", "", "nginx.conf file location")
+	fmtCmd.Flags().Bool("check", false, "exit with non-zero status if the file is not formatted")
+	fmtCmd.Flags().Bool("diff", false, "print the diff instead of rewriting the file")
+	fmtCmd.Flags().IntP("indent", "i", 4, "number of spaces per indentation level")
+	fmtCmd.Flags().BoolP("tabs", "t", false, "indent with tabs")
+
+	return fmtCmd
+}
+
+func NewRewriteCommand() *cobra.Command {
+	rewriteCmd := &cobra.Command{
+		Use:   "rewrite",
+		Short: "A nginx rewrite and return simulator",
+		RunE:  RunNgxRewriteSimulator,
+	}
+
+	rewriteCmd.Flags().StringP("file", "f", "", "nginx.conf file location")
+	rewriteCmd.Flags().BoolP("single", "s", false, "parse single file or not")
+	rewriteCmd.Flags().StringP("url", "u", "", "target url, e.g: http://localhost:80/my-location")
+	rewriteCmd.Flags().StringP("method", "X", "GET", "request method")
+	rewriteCmd.Flags().StringSliceP("header", "H", []string{}, "request header, e.g: \"Cookie: a=b\"")
+	rewriteCmd.Flags().StringSlice("files", []string{}, "files that exist when evaluating file conditions and try_files")
+	rewriteCmd.Flags().String("remote-addr", "127.0.0.1", "client address, used for $remote_addr and geo blocks")
+
+	return rewriteCmd
+}
+
+func NewLintCommand() *cobra.Command {
+	lintCmd := &cobra.Command{
+		Use:   "lint",
+		Short: "A nginx config linter",
+		RunE:  RunLintNgx,
+		// findings are not usage errors
+		SilenceUsage: true,
+	}
+
+	lintCmd.Flags().StringP("file", "f", "", "nginx.conf file location")
+	lintCmd.Flags().BoolP("single", "s", false, "parse single file or not")
+	lintCmd.Flags().StringSlice("disable", []string{}, "rule ids to disable, e.g: server-tokens,if-in-location")
+	lintCmd.Flags().Bool("security", false, "also run the security rules for TLS, headers and access control")
+	lintCmd.Flags().String("format", "text", "output format: text, json or sarif")
+
+	return lintCmd
+}
+
+func NewLspCommand() *cobra.Command {
+	lspCmd := &cobra.Command{
+		Use:   "lsp",
+		Short: "A nginx config language server over stdio",
+		RunE:  RunNgxLanguageServer,
+	}
+
+	lspCmd.Flags().StringP("file", "f", "", "main nginx.conf the edited files are included from, defaults to nginx.conf of the workspace")
+
+	return lspCmd
+}
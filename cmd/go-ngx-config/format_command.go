@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/adityals/go-ngx-config/internal/diff"
+	"github.com/adityals/go-ngx-config/pkg/crossplane"
+	"github.com/adityals/go-ngx-config/pkg/parser"
+	"github.com/spf13/cobra"
+)
+
+func RunFormatNgx(cmd *cobra.Command, args []string) error {
+	filePath, err := cmd.Flags().GetString("file")
+	if err != nil {
+		return err
+	}
+
+	check, err := cmd.Flags().GetBool("check")
+	if err != nil {
+		return err
+	}
+
+	showDiff, err := cmd.Flags().GetBool("diff")
+	if err != nil {
+		return err
+	}
+
+	indent, err := cmd.Flags().GetInt("indent")
+	if err != nil {
+		return err
+	}
+
+	tabs, err := cmd.Flags().GetBool("tabs")
+	if err != nil {
+		return err
+	}
+
+	if filePath == "" {
+		return errors.New("file is required")
+	}
+
+	original, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	// included files are formatted on their own, so their directives
+	// can't be checked against the context they're included from
+	ast, err := parser.NewNgxConfParser(filePath, &crossplane.ParseOptions{
+		SingleFile:                true,
+		ParseComments:             true,
+		StopParsingOnError:        true,
+		SkipDirectiveContextCheck: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	formatted, err := crossplane.BuildString(ast.Config[0].Parsed, &crossplane.BuildOptions{
+		Indent:             indent,
+		Tabs:               tabs,
+		PreserveBlankLines: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	if formatted == string(original) {
+		return nil
+	}
+
+	if showDiff {
+		fmt.Print(diff.Unified(filePath+".orig", filePath, string(original), formatted))
+	}
+
+	if check {
+		return fmt.Errorf("%s is not formatted", filePath)
+	}
+
+	if showDiff {
+		return nil
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filePath, []byte(formatted), info.Mode())
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/adityals/go-ngx-config/pkg/crossplane"
+	"github.com/adityals/go-ngx-config/pkg/lint"
+	"github.com/adityals/go-ngx-config/pkg/parser"
+	"github.com/adityals/go-ngx-config/pkg/sarif"
+	"github.com/spf13/cobra"
+)
+
+func RunLintNgx(cmd *cobra.Command, args []string) error {
+	filePath, err := cmd.Flags().GetString("file")
+	if err != nil {
+		return err
+	}
+
+	singleFile, err := cmd.Flags().GetBool("single")
+	if err != nil {
+		return err
+	}
+
+	disabled, err := cmd.Flags().GetStringSlice("disable")
+	if err != nil {
+		return err
+	}
+
+	security, err := cmd.Flags().GetBool("security")
+	if err != nil {
+		return err
+	}
+
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+
+	if filePath == "" {
+		return errors.New("file is required")
+	}
+
+	payload, err := parser.NewNgxConfParser(filePath, &crossplane.ParseOptions{
+		SingleFile: singleFile,
+	})
+	if err != nil {
+		return err
+	}
+
+	available := lint.DefaultRules()
+	if security {
+		available = append(available, lint.SecurityRules()...)
+	}
+
+	skipped := map[string]bool{}
+	for _, id := range disabled {
+		skipped[id] = true
+	}
+
+	rules := []lint.Rule{}
+	for _, rule := range available {
+		if !skipped[rule.ID()] {
+			rules = append(rules, rule)
+		}
+	}
+
+	findings, err := lint.NewLinter(rules...).Lint(payload)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "text":
+		for _, perr := range payload.Errors {
+			line := 0
+			if perr.Line != nil {
+				line = *perr.Line
+			}
+			fmt.Printf("%s:%d: error: [%s] %s\n", perr.File, line, perr.Kind, perr.Message())
+		}
+		for _, finding := range findings {
+			fmt.Printf("%s:%d: %s: [%s] %s\n", finding.File, finding.Line, finding.Severity, finding.Rule, finding.Message)
+		}
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		report := struct {
+			Errors   []crossplane.PayloadError `json:"errors"`
+			Findings []lint.Finding            `json:"findings"`
+		}{payload.Errors, findings}
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	case "sarif":
+		log := sarif.NewParseLog(cmd.Root().Version, payload)
+		log.AddFindings(rules, findings)
+		if err := log.Write(os.Stdout); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+
+	issues := len(payload.Errors)
+	for _, finding := range findings {
+		if finding.Severity != lint.SeverityInfo {
+			issues++
+		}
+	}
+
+	if issues > 0 {
+		return fmt.Errorf("found %d issue(s)", issues)
+	}
+
+	return nil
+}
@@ -2,10 +2,15 @@ package main
 
 import (
 	"errors"
+	"strings"
 	"time"
 
-	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/pkg/crossplane"
+	"github.com/adityals/go-ngx-config/pkg/effective"
 	"github.com/adityals/go-ngx-config/pkg/matcher"
+	"github.com/adityals/go-ngx-config/pkg/parser"
+	"github.com/adityals/go-ngx-config/pkg/rewrite"
+	"github.com/adityals/go-ngx-config/pkg/upstream"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -30,11 +35,27 @@ func RunNgxLocationTester(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	files, err := cmd.Flags().GetStringSlice("files")
+	if err != nil {
+		return err
+	}
+
+	showEffective, err := cmd.Flags().GetBool("effective")
+	if err != nil {
+		return err
+	}
+
 	logrus.Info("Single File: ", singleFile)
 
-	match, err := matcher.NewLocationMatcher(filePath, targetUrl, &crossplane.ParseOptions{
-		SingleFile:     singleFile,
-		CombineConfigs: true,
+	payload, err := parser.NewNgxConfParser(filePath, &crossplane.ParseOptions{
+		SingleFile: singleFile,
+	})
+	if err != nil {
+		return err
+	}
+
+	match, err := matcher.NewLocationMatcherFromPayloadWithOptions(payload, targetUrl, &matcher.MatchOptions{
+		FileSystem: matcher.FileList(files),
 	})
 	if err != nil {
 		return err
@@ -46,9 +67,61 @@ func RunNgxLocationTester(cmd *cobra.Command, args []string) error {
 
 	elapsed := time.Since(startTime)
 
+	for _, regexErr := range match.RegexErrors {
+		logrus.Warnf("[Regex] %s:%d: %s", regexErr.File, regexErr.Line, regexErr.Error)
+	}
+
+	if match.Server != nil {
+		logrus.Info("[Server] Listen: ", match.Server.MatchListen)
+		logrus.Info("[Server] Name: ", match.Server.MatchName)
+		logrus.Info("[Server] Default: ", match.Server.IsDefault)
+	}
+
+	for i, location := range match.Chain {
+		logrus.Infof("[Chain] %d: location %s %s", i, location.MatchModifer, location.MatchPath)
+	}
+
+	for i, hop := range match.Hops {
+		logrus.Infof("[Hop] %d: %s %s -> location %s %s (status %d)", i, hop.Directive, hop.Uri, hop.MatchModifer, hop.MatchPath, hop.Status)
+	}
+
+	logrus.Info("[Match] Status: ", match.Status)
 	logrus.Info("[Match] Modifier: ", match.MatchModifer)
 	logrus.Info("[Match] Path: ", match.MatchPath)
 
+	for i, capture := range match.Captures {
+		if i > 0 {
+			logrus.Infof("[Match] $%d: %s", i, capture)
+		}
+	}
+	for name, capture := range match.NamedCaptures {
+		logrus.Infof("[Match] $%s: %s", name, capture)
+	}
+
+	// rewrites are followed to know which location passes the request
+	target, err := upstream.NewUpstreamResolverFromPayload(payload, targetUrl, &rewrite.SimulateOptions{
+		FileSystem: matcher.FileList(files),
+	})
+	if err != nil {
+		return err
+	}
+	logTarget(target)
+
+	if showEffective {
+		config, err := effective.NewEffectiveConfigFromPayload(payload, targetUrl, &matcher.MatchOptions{
+			FileSystem: matcher.FileList(files),
+		})
+		if err != nil {
+			return err
+		}
+		logEffective(config)
+	}
+
+	if match.Directives.Block == nil {
+		logrus.Info("Process time: ", time.Since(startTime))
+		return nil
+	}
+
 	logrus.Info("[Match] --- Directives Inside Block --- ")
 	for _, d := range *match.Directives.Block {
 		logrus.Info("[Match] Name: ", d.Directive)
@@ -63,3 +136,18 @@ func RunNgxLocationTester(cmd *cobra.Command, args []string) error {
 	return nil
 
 }
+
+// logEffective prints the effective value of every directive with the level
+// it comes from, and the inherited values it replaces.
+func logEffective(config *effective.Config) {
+	logrus.Info("[Effective] --- Effective Directives --- ")
+	for _, setting := range config.Settings {
+		for _, value := range setting.Values {
+			logrus.Infof("[Effective] %s %s (%s, %s:%d)", value.Directive.Directive, strings.Join(value.Directive.Args, " "), value.Level, value.File, value.Directive.Line)
+		}
+		for _, value := range setting.Replaced {
+			logrus.Infof("[Effective] replaced: %s %s (%s, %s:%d)", value.Directive.Directive, strings.Join(value.Directive.Args, " "), value.Level, value.File, value.Directive.Line)
+		}
+	}
+	logrus.Info("[Effective] --- End of Effective Directives --- ")
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+
+	"github.com/adityals/go-ngx-config/pkg/lsp"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func RunNgxLanguageServer(cmd *cobra.Command, args []string) error {
+	filePath, err := cmd.Flags().GetString("file")
+	if err != nil {
+		return err
+	}
+
+	// stdout is for the protocol, logs go to stderr
+	logrus.SetOutput(os.Stderr)
+	logrus.Info("Starting language server")
+
+	server := lsp.NewLanguageServer(os.Stdin, os.Stdout, &lsp.Options{
+		Config: filePath,
+	})
+	return server.Serve()
+}
@@ -13,9 +13,17 @@ func main() {
 	rootCmd := NewRootCommand()
 	parseCmd := NewParseCommand()
 	locationTesterCmd := NewLocationTesterCommand()
+	formatCmd := NewFormatCommand()
+	rewriteCmd := NewRewriteCommand()
+	lintCmd := NewLintCommand()
+	lspCmd := NewLspCommand()
 
 	rootCmd.AddCommand(parseCmd)
 	rootCmd.AddCommand(locationTesterCmd)
+	rootCmd.AddCommand(formatCmd)
+	rootCmd.AddCommand(rewriteCmd)
+	rootCmd.AddCommand(lintCmd)
+	rootCmd.AddCommand(lspCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -3,11 +3,13 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"time"
 
-	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/pkg/crossplane"
 	"github.com/adityals/go-ngx-config/pkg/parser"
+	"github.com/adityals/go-ngx-config/pkg/sarif"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -32,17 +34,45 @@ func RunParseNgx(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+
+	fromDump, err := cmd.Flags().GetBool("from-dump")
+	if err != nil {
+		return err
+	}
+
 	logrus.Info("Single File: ", singleFile)
 
-	ast, err := parser.NewNgxConfParser(filePath, &crossplane.ParseOptions{
+	opts := &crossplane.ParseOptions{
 		SingleFile:     singleFile,
 		CombineConfigs: true,
-	})
+	}
+
+	var ast *crossplane.Payload
+	if fromDump {
+		ast, err = parseDump(filePath, opts)
+	} else {
+		ast, err = parser.NewNgxConfParser(filePath, opts)
+	}
 	if err != nil {
 		return err
 	}
 
-	ast_json, err := json.MarshalIndent(ast, "", "  ")
+	dumpFile := "dump.json"
+	var ast_json []byte
+	switch format {
+	case "json":
+		ast_json, err = json.MarshalIndent(ast, "", "  ")
+	case "sarif":
+		// only the parse errors are reported, e.g. for code scanning
+		dumpFile = "dump.sarif"
+		ast_json, err = json.MarshalIndent(sarif.NewParseLog(cmd.Root().Version, ast), "", "  ")
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
 	if err != nil {
 		return err
 	}
@@ -55,7 +85,7 @@ func RunParseNgx(cmd *cobra.Command, args []string) error {
 			}
 		}
 
-		dumpAstJsonFile := outputFilePath + "/dump.json"
+		dumpAstJsonFile := outputFilePath + "/" + dumpFile
 		f, err := os.Create(dumpAstJsonFile)
 		if err != nil {
 			return err
@@ -76,3 +106,18 @@ func RunParseNgx(cmd *cobra.Command, args []string) error {
 	return nil
 
 }
+
+// parseDump parses the nginx -T output of a file, or of stdin for "-".
+func parseDump(filePath string, opts *crossplane.ParseOptions) (*crossplane.Payload, error) {
+	if filePath == "-" {
+		return parser.NewNgxConfDumpParser(os.Stdin, opts)
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parser.NewNgxConfDumpParser(f, opts)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/adityals/go-ngx-config/pkg/crossplane"
+	"github.com/adityals/go-ngx-config/pkg/matcher"
+	"github.com/adityals/go-ngx-config/pkg/rewrite"
+	"github.com/adityals/go-ngx-config/pkg/upstream"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func RunNgxRewriteSimulator(cmd *cobra.Command, args []string) error {
+	startTime := time.Now()
+
+	logrus.Info("Simulate rewrites")
+
+	filePath, err := cmd.Flags().GetString("file")
+	if err != nil {
+		return err
+	}
+
+	singleFile, err := cmd.Flags().GetBool("single")
+	if err != nil {
+		return err
+	}
+
+	targetUrl, err := cmd.Flags().GetString("url")
+	if err != nil {
+		return err
+	}
+
+	method, err := cmd.Flags().GetString("method")
+	if err != nil {
+		return err
+	}
+
+	headerFlags, err := cmd.Flags().GetStringSlice("header")
+	if err != nil {
+		return err
+	}
+
+	files, err := cmd.Flags().GetStringSlice("files")
+	if err != nil {
+		return err
+	}
+
+	remoteAddr, err := cmd.Flags().GetString("remote-addr")
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{}
+	for _, header := range headerFlags {
+		if idx := strings.Index(header, ":"); idx > 0 {
+			headers[strings.TrimSpace(header[:idx])] = strings.TrimSpace(header[idx+1:])
+		}
+	}
+
+	logrus.Info("Single File: ", singleFile)
+
+	sim, err := rewrite.NewRewriteSimulator(filePath, targetUrl, &crossplane.ParseOptions{
+		SingleFile: singleFile,
+	}, &rewrite.SimulateOptions{
+		Method:     method,
+		Headers:    headers,
+		RemoteAddr: remoteAddr,
+		FileSystem: matcher.FileList(files),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, regexErr := range sim.RegexErrors {
+		logrus.Warnf("[Regex] %s:%d: %s", regexErr.File, regexErr.Line, regexErr.Error)
+	}
+
+	for _, name := range sim.Unresolved {
+		logrus.Warnf("[Variable] $%s cannot be resolved", name)
+	}
+
+	for i, step := range sim.Steps {
+		logrus.Infof("[Step] %d: line %d: %s %s => %s", i, step.Line, step.Directive, strings.Join(step.Args, " "), step.Result)
+	}
+
+	for i, hop := range sim.Hops {
+		logrus.Infof("[Hop] %d: %s %s -> location %s %s (status %d)", i, hop.Directive, hop.Uri, hop.MatchModifer, hop.MatchPath, hop.Status)
+	}
+
+	if sim.Match != nil {
+		logrus.Infof("[Rewrite] Location: %s %s", sim.Match.MatchModifer, sim.Match.MatchPath)
+	}
+	logrus.Info("[Rewrite] Uri: ", sim.Uri)
+	logrus.Info("[Rewrite] Args: ", sim.Args)
+	logrus.Info("[Rewrite] Cycles: ", sim.Cycles)
+
+	if sim.Status != 0 {
+		logrus.Info("[Rewrite] Status: ", sim.Status)
+	}
+	if sim.Location != "" {
+		logrus.Info("[Rewrite] Redirect: ", sim.Location)
+	}
+	if sim.Body != "" {
+		logrus.Info("[Rewrite] Body: ", sim.Body)
+	}
+
+	logTarget(upstream.NewUpstreamResolverFromSimulation(sim))
+
+	elapsed := time.Since(startTime)
+	logrus.Info("Process time: ", elapsed)
+
+	return nil
+}
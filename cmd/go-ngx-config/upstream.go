@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/adityals/go-ngx-config/pkg/upstream"
+	"github.com/sirupsen/logrus"
+)
+
+// logTarget prints where the request is passed to.
+func logTarget(target *upstream.Target) {
+	if target == nil {
+		logrus.Info("[Upstream] Not passed to any upstream")
+		return
+	}
+
+	for _, name := range target.Unresolved {
+		logrus.Warnf("[Variable] $%s cannot be resolved", name)
+	}
+
+	logrus.Infof("[Upstream] %s %s (line %d)", target.Directive, target.Pass, target.Line)
+	logrus.Info("[Upstream] Address: ", target.Address)
+	if target.Uri != "" {
+		logrus.Info("[Upstream] Uri: ", target.Uri)
+	}
+	if target.UriError != "" {
+		logrus.Warn("[Upstream] ", target.UriError)
+	}
+
+	if target.Upstream == nil {
+		return
+	}
+
+	u := target.Upstream
+	logrus.Infof("[Upstream] Block: %s (%s)", u.Name, u.File)
+	logrus.Info("[Upstream] Method: ", u.Method)
+	if u.Key != "" {
+		logrus.Infof("[Upstream] Key: %s (consistent: %t)", u.Key, u.Consistent)
+	}
+	for i, server := range u.Servers {
+		logrus.Infof("[Upstream] Server %d: %s weight=%d max_fails=%d fail_timeout=%s backup=%t down=%t",
+			i, server.Address, server.Weight, server.MaxFails, server.FailTimeout, server.Backup, server.Down)
+	}
+}
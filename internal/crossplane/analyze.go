@@ -85,8 +85,10 @@ func analyze(fname string, stmt Directive, term string, ctx blockCtx, options *P
 	if options.ErrorOnUnknownDirectives && !knownDirective {
 		return ParseError{
 			what: fmt.Sprintf(`unknown directive "%s"`, stmt.Directive),
+			kind: KindUnknownDirective,
 			file: &fname,
 			line: &stmt.Line,
+			rng:  stmt.Range,
 		}
 	}
 
@@ -109,8 +111,10 @@ func analyze(fname string, stmt Directive, term string, ctx blockCtx, options *P
 		if len(ctxMasks) == 0 {
 			return ParseError{
 				what: fmt.Sprintf(`"%s" directive is not allowed here`, stmt.Directive),
+				kind: KindDirectiveNotAllowed,
 				file: &fname,
 				line: &stmt.Line,
+				rng:  stmt.Range,
 			}
 		}
 	}
@@ -121,19 +125,22 @@ func analyze(fname string, stmt Directive, term string, ctx blockCtx, options *P
 
 	// do this in reverse because we only throw errors at the end if no masks
 	// are valid, and typically the first bit mask is what the parser expects
-	var what string
+	var what, kind string
+	rng := stmt.Range
 	for i := 0; i < len(ctxMasks); i++ {
 		mask := ctxMasks[i]
 
 		// if the directive isn't a block but should be according to the mask
 		if (mask&ngxConfBlock) != 0 && term != "{" {
 			what = fmt.Sprintf(`directive "%s" has no opening "{"`, stmt.Directive)
+			kind = KindMissingOpeningBrace
 			continue
 		}
 
 		// if the directive is a block but shouldn't be according to the mask
 		if (mask&ngxConfBlock) == 0 && term != ";" {
 			what = fmt.Sprintf(`directive "%s" is not terminated by ";"`, stmt.Directive)
+			kind = KindMissingSemicolon
 			continue
 		}
 
@@ -146,8 +153,14 @@ func analyze(fname string, stmt Directive, term string, ctx blockCtx, options *P
 			return nil
 		} else if (mask&ngxConfFlag) != 0 && len(stmt.Args) == 1 && !validFlag(stmt.Args[0]) {
 			what = fmt.Sprintf(`invalid value "%s" in "%s" directive, it must be "on" or "off"`, stmt.Args[0], stmt.Directive)
+			kind = KindInvalidFlag
+			if len(stmt.ArgRanges) > 0 {
+				rng = &stmt.ArgRanges[0]
+			}
 		} else {
 			what = fmt.Sprintf(`invalid number of arguments in "%s" directive`, stmt.Directive)
+			kind = KindInvalidNumberOfArguments
+			rng = stmt.Range
 		}
 	}
 
@@ -155,6 +168,8 @@ func analyze(fname string, stmt Directive, term string, ctx blockCtx, options *P
 		what: what,
 		file: &fname,
 		line: &stmt.Line,
+		kind: kind,
+		rng:  rng,
 	}
 }
 
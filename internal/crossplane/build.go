@@ -0,0 +1,328 @@
+package crossplane
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BuildOptions determine how a config is rendered back to nginx syntax.
+type BuildOptions struct {
+	// Number of spaces used for each level of indentation. Defaults to 4.
+	Indent int
+
+	// If true, indent with tabs instead of spaces.
+	Tabs bool
+
+	// If true, a single blank line is kept between directives that were
+	// separated by one or more blank lines, based on their line numbers.
+	PreserveBlankLines bool
+}
+
+// BuildConfig writes a parsed config file back as nginx syntax.
+func BuildConfig(w io.Writer, config Config, options *BuildOptions) error {
+	return Build(w, config.Parsed, options)
+}
+
+// Build writes the given directives as nginx syntax.
+func Build(w io.Writer, block []Directive, options *BuildOptions) error {
+	if options == nil {
+		options = &BuildOptions{}
+	}
+
+	indent := strings.Repeat(" ", 4)
+	if options.Tabs {
+		indent = "\t"
+	} else if options.Indent > 0 {
+		indent = strings.Repeat(" ", options.Indent)
+	}
+
+	b := builder{
+		Writer:  bufio.NewWriter(w),
+		indent:  indent,
+		options: options,
+	}
+	b.buildBlock(block, 0)
+	if len(block) > 0 {
+		b.WriteString("\n")
+	}
+	return b.Flush()
+}
+
+// BuildString renders the given directives as nginx syntax.
+func BuildString(block []Directive, options *BuildOptions) (string, error) {
+	var sb strings.Builder
+	if err := Build(&sb, block, options); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+type builder struct {
+	*bufio.Writer
+	indent  string
+	options *BuildOptions
+}
+
+func (b *builder) buildBlock(block []Directive, depth int) {
+	for i, stmt := range block {
+		if i > 0 {
+			prev := block[i-1]
+
+			// a comment on the same line as the previous directive was found
+			// inside or after its args, so keep it on that line
+			if stmt.IsComment() && stmt.Line == prev.Line && !prev.IsComment() && !prev.IsBlock() {
+				b.WriteString(" #" + *stmt.Comment)
+				continue
+			}
+
+			b.WriteString("\n")
+			if b.options.PreserveBlankLines && stmt.Line > endLine(prev)+1 {
+				b.WriteString("\n")
+			}
+		}
+
+		b.WriteString(strings.Repeat(b.indent, depth))
+
+		if stmt.IsComment() {
+			b.WriteString("#" + *stmt.Comment)
+			continue
+		}
+
+		b.WriteString(buildDirective(stmt))
+
+		if !stmt.IsBlock() {
+			b.WriteString(";")
+			continue
+		}
+
+		if len(*stmt.Block) == 0 {
+			b.WriteString(" {}")
+			continue
+		}
+
+		b.WriteString(" {\n")
+		b.buildBlock(*stmt.Block, depth+1)
+		b.WriteString("\n" + strings.Repeat(b.indent, depth) + "}")
+	}
+}
+
+// endLine guesses the line a directive ends on, assuming that the closing
+// brace of a non-empty block is on the line after its last directive.
+func endLine(stmt Directive) int {
+	if !stmt.IsBlock() || len(*stmt.Block) == 0 {
+		return stmt.Line
+	}
+	block := *stmt.Block
+	return endLine(block[len(block)-1]) + 1
+}
+
+// buildDirective renders a directive name and its args without a terminator.
+func buildDirective(stmt Directive) string {
+	args := make([]string, 0, len(stmt.Args))
+	for _, arg := range stmt.Args {
+		args = append(args, enquote(arg))
+	}
+
+	// restore the parentheses removed by prepareIfArgs
+	if stmt.Directive == "if" {
+		return "if (" + strings.Join(args, " ") + ")"
+	}
+
+	if len(args) == 0 {
+		return enquote(stmt.Directive)
+	}
+	return enquote(stmt.Directive) + " " + strings.Join(args, " ")
+}
+
+// needsQuote reports whether an arg would be split or misread by the lexer
+// if it was written as is.
+func needsQuote(s string) bool {
+	if s == "" || s[0] == '#' {
+		return true
+	}
+
+	// a "${" is lexed as parameter expansion until the closing brace, any
+	// other brace would end the directive or open a block
+	if strings.ContainsAny(s, "{}") && !isParamExpansion(s) {
+		return true
+	}
+
+	// quotes are only special at the start of a token
+	if s[0] == '"' || s[0] == '\'' {
+		return true
+	}
+
+	for _, char := range s {
+		if char == ';' || isSpace(string(char)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isParamExpansion reports whether every brace in s belongs to a "${var}".
+func isParamExpansion(s string) bool {
+	for {
+		start := strings.Index(s, "{")
+		if start < 0 {
+			return !strings.Contains(s, "}")
+		}
+		if start == 0 || s[start-1] != '$' || strings.Contains(s[:start], "}") {
+			return false
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			return false
+		}
+		s = s[start+end+1:]
+	}
+}
+
+// enquote quotes s if needed so that it is lexed back as a single token.
+func enquote(s string) string {
+	if !needsQuote(s) {
+		return s
+	}
+
+	quote := `"`
+	if strings.Contains(s, `"`) && !strings.Contains(s, `'`) {
+		quote = `'`
+	}
+
+	return quote + strings.ReplaceAll(s, quote, `\`+quote) + quote
+}
+
+// BuildFiles writes every config of an uncombined payload back to its file,
+// starting from the main config and following the include indices. If dir is
+// not empty, the files are written under dir keeping their layout relative to
+// the main config, otherwise they are written in place.
+func BuildFiles(payload Payload, dir string, options *BuildOptions) error {
+	if len(payload.Config) == 0 {
+		return nil
+	}
+
+	root := filepath.Dir(payload.Config[0].File)
+
+	// a file included from several contexts is parsed once per context, the
+	// instance with the fewest errors kept the most directives
+	preferred := map[string]int{}
+	for idx, config := range payload.Config {
+		if best, ok := preferred[config.File]; !ok || len(config.Errors) < len(payload.Config[best].Errors) {
+			preferred[config.File] = idx
+		}
+	}
+
+	built := make([]bool, len(payload.Config))
+	written := map[string]bool{}
+	pending := []int{0}
+
+	for len(pending) > 0 {
+		idx := pending[0]
+		pending = pending[1:]
+
+		if built[idx] {
+			continue
+		}
+		built[idx] = true
+
+		config := payload.Config[idx]
+		includes, err := includedConfigs(payload, config.File, config.Parsed)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, includes...)
+
+		if written[config.File] {
+			continue
+		}
+		written[config.File] = true
+		config = payload.Config[preferred[config.File]]
+
+		path, err := buildPath(config, root, dir)
+		if err != nil {
+			return err
+		}
+
+		if err := buildFile(path, config, options); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// includedConfigs returns the config indices of every include in block.
+func includedConfigs(payload Payload, fromfile string, block []Directive) ([]int, error) {
+	indices := []int{}
+	for _, dir := range block {
+		if dir.IsInclude() {
+			for _, idx := range *dir.Includes {
+				if idx < 0 || idx >= len(payload.Config) {
+					line := dir.Line
+					return nil, ParseError{
+						what: fmt.Sprintf("include config with index: %d", idx),
+						kind: KindInvalidIncludeIndex,
+						file: &fromfile,
+						line: &line,
+						rng:  dir.Range,
+					}
+				}
+				indices = append(indices, idx)
+			}
+		}
+
+		if dir.IsBlock() {
+			inner, err := includedConfigs(payload, fromfile, *dir.Block)
+			if err != nil {
+				return nil, err
+			}
+			indices = append(indices, inner...)
+		}
+	}
+	return indices, nil
+}
+
+// buildPath returns where a config is written to when building into dir.
+func buildPath(config Config, root string, dir string) (string, error) {
+	if config.File == "" {
+		return "", errors.New("config has no file to be written to")
+	}
+
+	if dir == "" {
+		return config.File, nil
+	}
+
+	rel, err := filepath.Rel(root, config.File)
+	if err != nil {
+		return "", err
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("config %s is outside of %s", config.File, root)
+	}
+
+	return filepath.Join(dir, rel), nil
+}
+
+func buildFile(path string, config Config, options *BuildOptions) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := BuildConfig(f, config, options); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
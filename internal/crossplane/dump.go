@@ -0,0 +1,70 @@
+package crossplane
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// marker nginx -T writes before the content of every config file
+var dumpMarker = regexp.MustCompile(`^# configuration file (.+):$`)
+
+// SplitDump splits the output of nginx -T back into its config files,
+// returning them with the path of the main config, the first one. The
+// lines before the first file, like the result of the syntax test, are
+// skipped.
+func SplitDump(r io.Reader) (map[string]string, string, error) {
+	files := map[string]string{}
+	entry := ""
+	current := ""
+	var content strings.Builder
+
+	flush := func() {
+		if current == "" {
+			return
+		}
+		// nginx -T ends every file with an extra line feed
+		files[current] = strings.TrimSuffix(content.String(), "\n")
+		content.Reset()
+	}
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, "", err
+		}
+
+		if match := dumpMarker.FindStringSubmatch(strings.TrimRight(line, "\r\n")); match != nil {
+			flush()
+			current = match[1]
+			if entry == "" {
+				entry = current
+			}
+		} else if current != "" {
+			content.WriteString(line)
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+	flush()
+
+	if entry == "" {
+		return nil, "", errors.New("no configuration file found in the nginx -T output")
+	}
+	return files, entry, nil
+}
+
+// ParseDump parses the output of nginx -T as the include tree of its main
+// config, every file keeping its own line numbers.
+func ParseDump(r io.Reader, options *ParseOptions) (*Payload, error) {
+	files, entry, err := SplitDump(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFiles(files, entry, options)
+}
@@ -1,13 +1,40 @@
 package crossplane
 
 import (
+	"errors"
 	"fmt"
 )
 
+// ErrNoConfig is returned when there is no payload to work on.
+var ErrNoConfig = errors.New("no config can be computed")
+
+// Kinds of parse errors. They are stable, so tools can refer to them like
+// rule ids.
+const (
+	KindParseError               = "parse-error"
+	KindUnknownDirective         = "unknown-directive"
+	KindDirectiveNotAllowed      = "directive-not-allowed"
+	KindMissingOpeningBrace      = "missing-opening-brace"
+	KindMissingSemicolon         = "missing-semicolon"
+	KindInvalidFlag              = "invalid-flag"
+	KindInvalidNumberOfArguments = "invalid-number-of-arguments"
+	KindUnexpectedClosingBrace   = "unexpected-closing-brace"
+	KindUnexpectedEndOfFile      = "unexpected-end-of-file"
+	KindIncludeNotFound          = "include-not-found"
+	KindInvalidIncludeIndex      = "invalid-include-index"
+	KindIncludeCycle             = "include-cycle"
+)
+
 type ParseError struct {
 	what string
 	file *string
 	line *int
+	kind string
+	rng  *Range
+
+	// chain of the includes of a cycle, as file:line up to the file
+	// included again
+	chain []string
 }
 
 func (e ParseError) Error() string {
@@ -21,3 +48,46 @@ func (e ParseError) Error() string {
 
 	return e.what
 }
+
+// What returns the error message without its file and line.
+func (e ParseError) What() string {
+	return e.what
+}
+
+// File returns the file the error was found in, if known.
+func (e ParseError) File() *string {
+	return e.file
+}
+
+// Line returns the line the error was found on, if known.
+func (e ParseError) Line() *int {
+	return e.line
+}
+
+// Range returns the part of the file the error is about, if known.
+func (e ParseError) Range() *Range {
+	return e.rng
+}
+
+// IncludeChain returns the includes of an include cycle, as file:line up to
+// the file included again.
+func (e ParseError) IncludeChain() []string {
+	return e.chain
+}
+
+// Kind returns the kind of the error, KindParseError if it has none.
+func (e ParseError) Kind() string {
+	if e.kind == "" {
+		return KindParseError
+	}
+	return e.kind
+}
+
+// ErrorKind returns the kind of a parse error, KindParseError for any other
+// error.
+func ErrorKind(err error) string {
+	if e, ok := err.(ParseError); ok {
+		return e.Kind()
+	}
+	return KindParseError
+}
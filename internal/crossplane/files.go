@@ -0,0 +1,48 @@
+package crossplane
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ParseFiles parses a config tree held in memory, mapping the path of every
+// file to its content, starting with the entry file. Includes and their
+// globs are resolved against the paths of the map, relative to the entry.
+func ParseFiles(files map[string]string, entry string, options *ParseOptions) (*Payload, error) {
+	contents := make(map[string]string, len(files))
+	paths := make([]string, 0, len(files))
+	for path, content := range files {
+		path = filepath.Clean(path)
+		contents[path] = content
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	opts := *options
+	opts.Open = func(path string) (io.Reader, error) {
+		content, ok := contents[filepath.Clean(path)]
+		if !ok {
+			return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+		}
+		return strings.NewReader(content), nil
+	}
+	opts.Glob = func(pattern string) ([]string, error) {
+		pattern = filepath.Clean(pattern)
+		matches := []string{}
+		for _, path := range paths {
+			ok, err := filepath.Match(pattern, path)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				matches = append(matches, path)
+			}
+		}
+		return matches, nil
+	}
+
+	return Parse(filepath.Clean(entry), &opts)
+}
@@ -0,0 +1,123 @@
+package crossplane
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// IncludeGraph tells which file includes which of an uncombined payload.
+type IncludeGraph struct {
+	// Files are the parsed files, the main config first.
+	Files    []string      `json:"files"`
+	Includes []IncludeEdge `json:"includes"`
+}
+
+// IncludeEdge is an include directive with the files its pattern expanded
+// to.
+type IncludeEdge struct {
+	File    string   `json:"file"`
+	Line    int      `json:"line"`
+	Context []string `json:"context"`
+	Pattern string   `json:"pattern"`
+	Matches []string `json:"matches"`
+}
+
+// NewIncludeGraph returns the include graph of an uncombined payload. The
+// context of a file included from several contexts is the one of the file
+// including it.
+func NewIncludeGraph(payload *Payload) *IncludeGraph {
+	graph := &IncludeGraph{Files: []string{}, Includes: []IncludeEdge{}}
+	if len(payload.Config) == 0 {
+		return graph
+	}
+
+	seen := map[string]bool{}
+	for _, config := range payload.Config {
+		if !seen[config.File] {
+			seen[config.File] = true
+			graph.Files = append(graph.Files, config.File)
+		}
+	}
+
+	// configs are visited from the main one so their context is known
+	contexts := map[int]blockCtx{0: {}}
+	pending := []int{0}
+	for len(pending) > 0 {
+		idx := pending[0]
+		pending = pending[1:]
+		config := payload.Config[idx]
+		pending = append(pending, graph.addIncludes(payload, config.File, config.Parsed, contexts[idx], contexts)...)
+	}
+
+	return graph
+}
+
+// addIncludes adds the includes of a block, returning the configs they
+// reach for the first time.
+func (g *IncludeGraph) addIncludes(payload *Payload, file string, block []Directive, ctx blockCtx, contexts map[int]blockCtx) []int {
+	reached := []int{}
+
+	for _, d := range block {
+		if d.Directive == "include" && len(d.Args) == 1 {
+			edge := IncludeEdge{
+				File:    file,
+				Line:    d.Line,
+				Context: append([]string{}, ctx...),
+				Pattern: d.Args[0],
+				Matches: []string{},
+			}
+			if d.Includes != nil {
+				for _, idx := range *d.Includes {
+					if idx < 0 || idx >= len(payload.Config) {
+						continue
+					}
+					edge.Matches = append(edge.Matches, payload.Config[idx].File)
+					if _, ok := contexts[idx]; !ok {
+						contexts[idx] = append(blockCtx{}, ctx...)
+						reached = append(reached, idx)
+					}
+				}
+			}
+			g.Includes = append(g.Includes, edge)
+		}
+
+		if d.Block != nil {
+			inner := enterBlockCtx(d, append(blockCtx{}, ctx...))
+			reached = append(reached, g.addIncludes(payload, file, *d.Block, inner, contexts)...)
+		}
+	}
+
+	return reached
+}
+
+// IncludedBy returns the includes of a file by other files.
+func (g *IncludeGraph) IncludedBy(file string) []IncludeEdge {
+	edges := []IncludeEdge{}
+	for _, edge := range g.Includes {
+		for _, match := range edge.Matches {
+			if match == file {
+				edges = append(edges, edge)
+				break
+			}
+		}
+	}
+	return edges
+}
+
+// Unused returns the sorted files of the candidates the config never
+// includes, e.g. the snippets of a directory.
+func (g *IncludeGraph) Unused(candidates []string) []string {
+	used := map[string]bool{}
+	for _, file := range g.Files {
+		used[filepath.Clean(file)] = true
+	}
+
+	unused := []string{}
+	for _, file := range candidates {
+		if !used[filepath.Clean(file)] {
+			unused = append(unused, file)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
@@ -0,0 +1,112 @@
+package crossplane
+
+import "fmt"
+
+// Inlined is the main config of an uncombined payload with its include
+// directives replaced by the directives of the files they include, every
+// directive knowing the file it was parsed from.
+type Inlined struct {
+	Parsed []Directive
+
+	main string
+
+	// file of every inlined block, and the files of its directives by index
+	blocks map[*[]Directive]string
+	files  map[*[]Directive][]string
+}
+
+// Inline follows the includes of an uncombined payload from its main config.
+// A file including itself, directly or not, is not followed again.
+func Inline(payload *Payload) (*Inlined, error) {
+	in := &Inlined{
+		Parsed: []Directive{},
+		blocks: map[*[]Directive]string{},
+		files:  map[*[]Directive][]string{},
+	}
+	if len(payload.Config) == 0 {
+		return in, nil
+	}
+
+	in.main = payload.Config[0].File
+	parsed, files, err := in.inline(payload, 0, payload.Config[0].Parsed, map[int]bool{0: true})
+	if err != nil {
+		return nil, err
+	}
+
+	in.Parsed = parsed
+	in.blocks[&in.Parsed] = in.main
+	in.files[&in.Parsed] = files
+	return in, nil
+}
+
+// inline also returns the file of every inlined directive.
+func (in *Inlined) inline(payload *Payload, idx int, block []Directive, including map[int]bool) ([]Directive, []string, error) {
+	file := payload.Config[idx].File
+	inlined := make([]Directive, 0, len(block))
+	files := make([]string, 0, len(block))
+
+	for _, d := range block {
+		if d.IsBlock() {
+			inner, innerFiles, err := in.inline(payload, idx, *d.Block, including)
+			if err != nil {
+				return nil, nil, err
+			}
+			d.Block = &inner
+			in.blocks[d.Block] = file
+			in.files[d.Block] = innerFiles
+		}
+
+		if !d.IsInclude() {
+			inlined = append(inlined, d)
+			files = append(files, file)
+			continue
+		}
+
+		for _, included := range *d.Includes {
+			if included < 0 || included >= len(payload.Config) {
+				line := d.Line
+				return nil, nil, ParseError{
+					what: fmt.Sprintf("include config with index: %d", included),
+					kind: KindInvalidIncludeIndex,
+					file: &file,
+					line: &line,
+					rng:  d.Range,
+				}
+			}
+			// a file including itself would never end
+			if including[included] {
+				continue
+			}
+
+			including[included] = true
+			inner, innerFiles, err := in.inline(payload, included, payload.Config[included].Parsed, including)
+			delete(including, included)
+			if err != nil {
+				return nil, nil, err
+			}
+			inlined = append(inlined, inner...)
+			files = append(files, innerFiles...)
+		}
+	}
+
+	return inlined, files, nil
+}
+
+// File returns the file an inlined block was parsed from, the main config
+// for Parsed and the blocks that aren't inlined.
+func (in *Inlined) File(block *[]Directive) string {
+	if file, ok := in.blocks[block]; ok {
+		return file
+	}
+	return in.main
+}
+
+// DirectiveFile returns the file the directive at index idx of an inlined
+// block was parsed from, which differs from the file of the block when it
+// was included.
+func (in *Inlined) DirectiveFile(block *[]Directive, idx int) string {
+	if files, ok := in.files[block]; ok && idx < len(files) {
+		return files[idx]
+	}
+	return in.File(block)
+}
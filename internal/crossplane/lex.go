@@ -4,226 +4,306 @@ import (
 	"bufio"
 	"io"
 	"strings"
+	"unicode/utf8"
 )
 
 type ngxToken struct {
 	Value    string
 	Line     int
+	Range    Range
 	IsQuoted bool
 	Error    error
 }
 
 type charLine struct {
-	char string
-	line int
+	char   string
+	line   int
+	column int
+	offset int
 }
 
-func lex(reader io.Reader) chan ngxToken {
-	return balanceBraces(tokenize(reader))
+// lexer reads the tokens of a config one by one as the parser pulls them,
+// unescaping the chars and keeping their position as it goes.
+type lexer struct {
+	reader *bufio.Reader
+	line   int
+	column int
+	offset int
+
+	// token being read and the tokens read but not pulled yet
+	token     []byte
+	tokenLine int
+	start     Position
+	end       Position
+	pending   []ngxToken
+	head      int
+	eof       bool
+
+	// braces seen so far, to fail on unbalanced ones
+	depth    int
+	lastLine int
+	last     Range
+	failed   bool
 }
 
-func balanceBraces(tokens chan ngxToken) chan ngxToken {
-	c := make(chan ngxToken)
-
-	go func() {
-		defer close(c)
-		depth := 0
-		line := 0
-		for t := range tokens {
-			line = t.Line
-			if t.Value == "}" && !t.IsQuoted {
-				depth--
-			} else if t.Value == "{" && !t.IsQuoted {
-				depth++
-			}
+func lex(reader io.Reader) *lexer {
+	return &lexer{
+		reader: bufio.NewReader(reader),
+		line:   1,
+		column: 1,
+	}
+}
 
-			// raise error if we ever have more right braces than left
-			if depth < 0 {
-				c <- ngxToken{
-					Error: ParseError{
-						what: `unexpected "}"`,
-						line: &line,
-					},
-				}
-				close(c)
-				return
-			}
-			c <- t
-		}
+// next returns the next token, or a zero token and false once the config is
+// read. A token with an error is returned if the braces are not balanced,
+// the config is not read any further then.
+func (lx *lexer) next() (ngxToken, bool) {
+	if lx.failed {
+		return ngxToken{}, false
+	}
 
+	t, ok := lx.read()
+	if !ok {
 		// raise error if we have less right braces than left at EOF
-		if depth > 0 {
-			c <- ngxToken{
+		if lx.depth > 0 {
+			lx.failed = true
+			line := lx.lastLine
+			end := Range{Start: lx.last.End, End: lx.last.End}
+			return ngxToken{
 				Error: ParseError{
 					what: `unexpected end of file, expecting "}"`,
+					kind: KindUnexpectedEndOfFile,
 					line: &line,
+					rng:  &end,
 				},
-			}
+			}, true
 		}
+		return ngxToken{}, false
+	}
+
+	lx.lastLine = t.Line
+	lx.last = t.Range
+	if t.Value == "}" && !t.IsQuoted {
+		lx.depth--
+	} else if t.Value == "{" && !t.IsQuoted {
+		lx.depth++
+	}
+
+	// raise error if we ever have more right braces than left
+	if lx.depth < 0 {
+		lx.failed = true
+		line := t.Line
+		rng := t.Range
+		return ngxToken{
+			Error: ParseError{
+				what: `unexpected "}"`,
+				kind: KindUnexpectedClosingBrace,
+				line: &line,
+				rng:  &rng,
+			},
+		}, true
+	}
+
+	return t, true
+}
 
-	}()
+// read returns the next token, whether the braces are balanced or not.
+func (lx *lexer) read() (ngxToken, bool) {
+	if lx.head == len(lx.pending) {
+		// the buffer is reused once every token was pulled
+		lx.pending = lx.pending[:0]
+		lx.head = 0
+		for len(lx.pending) == 0 && !lx.eof {
+			lx.scan()
+		}
+		if len(lx.pending) == 0 {
+			return ngxToken{}, false
+		}
+	}
 
-	return c
+	t := lx.pending[lx.head]
+	lx.head++
+	return t, true
 }
 
-func tokenize(reader io.Reader) chan ngxToken {
-	c := make(chan ngxToken)
-
-	go func() {
-		defer close(c)
-		var ok bool
-		var token string
-		var tokenLine int
-
-		it := lineCount(escapeChars(readChars(reader)))
-
-		for cl := range it {
-			// handle whitespace
-			if isSpace(cl.char) {
-				// if token complete yield it and reset token buffer
-				if len(token) > 0 {
-					c <- ngxToken{Value: token, Line: tokenLine, IsQuoted: false}
-					token = ""
-				}
-				// disregard until char isn't a whitespace character
-				for isSpace(cl.char) {
-					if cl, ok = <-it; !ok {
-						break
-					}
-				}
-			}
-
-			// if starting comment
-			if len(token) == 0 && cl.char == "#" {
-				lineAtStart := cl.line
-				for !strings.HasSuffix(cl.char, "\n") {
-					token += cl.char
-					if cl, ok = <-it; !ok {
-						break
-					}
-				}
-				c <- ngxToken{Value: token, Line: lineAtStart, IsQuoted: false}
-				token = ""
-				continue
+// scan reads chars until at least one token is complete, or the config
+// ends.
+func (lx *lexer) scan() {
+	cl, ok := lx.char()
+	if !ok {
+		if len(lx.token) > 0 {
+			lx.emit(false)
+		}
+		lx.eof = true
+		return
+	}
+
+	// handle whitespace
+	if isSpace(cl.char) {
+		// if token complete yield it and reset token buffer
+		if len(lx.token) > 0 {
+			lx.emit(false)
+		}
+		// disregard until char isn't a whitespace character
+		for isSpace(cl.char) {
+			if cl, ok = lx.char(); !ok {
+				break
 			}
-
-			if len(token) == 0 {
-				tokenLine = cl.line
+		}
+	}
+
+	// if starting comment
+	if len(lx.token) == 0 && cl.char == "#" {
+		lx.tokenLine = cl.line
+		lx.start = cl.position()
+		for !strings.HasSuffix(cl.char, "\n") {
+			lx.append(cl)
+			if cl, ok = lx.char(); !ok {
+				break
 			}
-
-			// handle parameter expansion syntax (ex: "${var[@]}")
-			if len(token) > 0 && strings.HasSuffix(token, "$") && cl.char == "{" {
-				for !strings.HasSuffix(token, "}") && !isSpace(cl.char) {
-					token += cl.char
-					if cl, ok = <-it; !ok {
-						break
-					}
-				}
+		}
+		lx.emit(false)
+		return
+	}
+
+	if len(lx.token) == 0 {
+		lx.tokenLine = cl.line
+		lx.start = cl.position()
+	}
+
+	// handle parameter expansion syntax (ex: "${var[@]}")
+	if len(lx.token) > 0 && lx.token[len(lx.token)-1] == '$' && cl.char == "{" {
+		for lx.token[len(lx.token)-1] != '}' && !isSpace(cl.char) {
+			lx.append(cl)
+			if cl, ok = lx.char(); !ok {
+				break
 			}
+		}
+	}
+
+	// if a quote is found, add the whole string to the token buffer
+	if cl.char == `"` || cl.char == "'" {
+		// if a quote is inside a token, treat it like any other char
+		if len(lx.token) > 0 {
+			lx.append(cl)
+			return
+		}
 
-			// if a quote is found, add the whole string to the token buffer
-			if cl.char == `"` || cl.char == "'" {
-				// if a quote is inside a token, treat it like any other char
-				if len(token) > 0 {
-					token += cl.char
-					continue
-				}
-
-				quote := cl.char
-				if cl, ok = <-it; !ok {
-					break
-				}
-				for cl.char != quote {
-					if cl.char == "\\"+quote {
-						token += quote
-					} else {
-						token += cl.char
-					}
-					if cl, ok = <-it; !ok {
-						break
-					}
-				}
-
-				// True because this is in quotes
-				c <- ngxToken{Value: token, Line: tokenLine, IsQuoted: true}
-				token = ""
-				continue
+		quote := cl.char
+		escaped := "\\" + quote
+		lx.end = cl.after()
+		if cl, ok = lx.char(); !ok {
+			return
+		}
+		for cl.char != quote {
+			if cl.char == escaped {
+				lx.token = append(lx.token, quote...)
+				lx.end = cl.after()
+			} else {
+				lx.append(cl)
 			}
-
-			// handle special characters that are treated like full tokens
-			if cl.char == "{" || cl.char == "}" || cl.char == ";" {
-				// if token complete yield it and reset token buffer
-				if len(token) > 0 {
-					c <- ngxToken{Value: token, Line: tokenLine, IsQuoted: false}
-					token = ""
-				}
-
-				// this character is a full token so yield it now
-				c <- ngxToken{Value: cl.char, Line: cl.line, IsQuoted: false}
-				continue
+			if cl, ok = lx.char(); !ok {
+				break
 			}
-
-			// append char to the token buffer
-			token += cl.char
 		}
-
-		if token != "" {
-			c <- ngxToken{Value: token, Line: tokenLine, IsQuoted: false}
+		// the range covers the closing quote too
+		if ok {
+			lx.end = cl.after()
 		}
 
-	}()
+		// True because this is in quotes
+		lx.emit(true)
+		return
+	}
 
-	return c
-}
+	// handle special characters that are treated like full tokens
+	if cl.char == "{" || cl.char == "}" || cl.char == ";" {
+		// if token complete yield it and reset token buffer
+		if len(lx.token) > 0 {
+			lx.emit(false)
+		}
 
-func readChars(reader io.Reader) chan string {
-	c := make(chan string)
+		// this character is a full token so yield it now
+		lx.pending = append(lx.pending, ngxToken{
+			Value: cl.char,
+			Line:  cl.line,
+			Range: Range{cl.position(), cl.after()},
+		})
+		return
+	}
+
+	// append char to the token buffer
+	lx.append(cl)
+}
 
-	go func() {
-		defer close(c)
-		scanner := bufio.NewScanner(reader)
-		scanner.Split(bufio.ScanRunes)
-		for scanner.Scan() {
-			c <- scanner.Text()
-		}
-	}()
+// append appends a char to the token buffer.
+func (lx *lexer) append(cl charLine) {
+	if cl.char == "" {
+		return
+	}
+	lx.token = append(lx.token, cl.char...)
+	lx.end = cl.after()
+}
 
-	return c
+// emit yields the token buffer and resets it.
+func (lx *lexer) emit(quoted bool) {
+	lx.pending = append(lx.pending, ngxToken{
+		Value:    string(lx.token),
+		Line:     lx.tokenLine,
+		Range:    Range{lx.start, lx.end},
+		IsQuoted: quoted,
+	})
+	lx.token = lx.token[:0]
 }
 
-func lineCount(chars chan string) chan charLine {
-	c := make(chan charLine)
+// char returns the next char, an escaped char with its backslash, and its
+// position. Carriage returns are skipped. Like reading a closed channel,
+// the char is zero once the config is read.
+func (lx *lexer) char() (charLine, bool) {
+	for {
+		r, size, err := lx.reader.ReadRune()
+		if err != nil {
+			return charLine{}, false
+		}
 
-	go func() {
-		defer close(c)
-		line := 1
-		for char := range chars {
-			if strings.HasSuffix(char, "\n") {
-				line++
+		offset := lx.offset
+		lx.offset += size
+		char := runeString(r)
+		if char == "\\" {
+			if r, size, err := lx.reader.ReadRune(); err == nil {
+				char += runeString(r)
+				lx.offset += size
 			}
-			c <- charLine{char: char, line: line}
 		}
-	}()
-
-	return c
-}
 
-func escapeChars(chars chan string) chan string {
-	c := make(chan string)
+		// Skip carriage return characters.
+		if char == "\r" || char == "\\\r" {
+			continue
+		}
 
-	go func() {
-		defer close(c)
-		for char := range chars {
-			if char == "\\" {
-				char += <-chars
-			}
-			// Skip carriage return characters.
-			if char == "\r" || char == "\\\r" {
-				continue
-			}
-			c <- char
+		cl := charLine{char: char, column: lx.column, offset: offset}
+		lx.column += utf8.RuneCountInString(char)
+		if strings.HasSuffix(char, "\n") {
+			lx.line++
+			lx.column = 1
 		}
-	}()
+		cl.line = lx.line
+		return cl, true
+	}
+}
 
-	return c
+// the ascii chars as strings, so most chars are read without allocating
+var asciiChars = func() [utf8.RuneSelf]string {
+	var chars [utf8.RuneSelf]string
+	for i := range chars {
+		chars[i] = string(rune(i))
+	}
+	return chars
+}()
+
+func runeString(r rune) string {
+	if r >= 0 && r < utf8.RuneSelf {
+		return asciiChars[r]
+	}
+	return string(r)
 }
@@ -0,0 +1,203 @@
+package crossplane
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type lexToken struct {
+	Value    string
+	Line     int
+	IsQuoted bool
+}
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    string
+		want    []lexToken
+		errKind string
+		errLine int
+	}{
+		{
+			name: "directives and blocks",
+			conf: "user nginx;\nhttp {\n  server {}\n}\n",
+			want: []lexToken{
+				{"user", 1, false}, {"nginx", 1, false}, {";", 1, false},
+				{"http", 2, false}, {"{", 2, false},
+				{"server", 3, false}, {"{", 3, false}, {"}", 3, false},
+				{"}", 4, false},
+			},
+		},
+		{
+			name: "quotes",
+			conf: `add_header X "a b" 'c;d' "";`,
+			want: []lexToken{
+				{"add_header", 1, false}, {"X", 1, false}, {"a b", 1, true},
+				{"c;d", 1, true}, {"", 1, true}, {";", 1, false},
+			},
+		},
+		{
+			name: "escaped quote and brace",
+			conf: `return 200 "say \"hi\"" a\{b;`,
+			want: []lexToken{
+				{"return", 1, false}, {"200", 1, false}, {`say "hi"`, 1, true},
+				{`a\{b`, 1, false}, {";", 1, false},
+			},
+		},
+		{
+			name: "quoted braces",
+			conf: `log_format main "}" '{';`,
+			want: []lexToken{
+				{"log_format", 1, false}, {"main", 1, false}, {"}", 1, true},
+				{"{", 1, true}, {";", 1, false},
+			},
+		},
+		{
+			name: "parameter expansion",
+			conf: `set $a ${b}c;`,
+			want: []lexToken{
+				{"set", 1, false}, {"$a", 1, false}, {"${b}c", 1, false}, {";", 1, false},
+			},
+		},
+		{
+			name: "comments",
+			conf: "# first\nlisten 80; # port\n",
+			want: []lexToken{
+				{"# first", 1, false}, {"listen", 2, false}, {"80", 2, false},
+				{";", 2, false}, {"# port", 2, false},
+			},
+		},
+		{
+			name: "carriage returns",
+			conf: "a b;\r\nc d;\r\n",
+			want: []lexToken{
+				{"a", 1, false}, {"b", 1, false}, {";", 1, false},
+				{"c", 2, false}, {"d", 2, false}, {";", 2, false},
+			},
+		},
+		{
+			name: "multiline quote",
+			conf: "a \"b\nc\" d;",
+			want: []lexToken{
+				{"a", 1, false}, {"b\nc", 1, true}, {"d", 2, false}, {";", 2, false},
+			},
+		},
+		{
+			name:    "stray closing brace",
+			conf:    "a;\n}\nb;",
+			want:    []lexToken{{"a", 1, false}, {";", 1, false}},
+			errKind: KindUnexpectedClosingBrace,
+			errLine: 2,
+		},
+		{
+			name: "end of file inside block",
+			conf: "http {\n  a;\n",
+			want: []lexToken{
+				{"http", 1, false}, {"{", 1, false}, {"a", 2, false}, {";", 2, false},
+			},
+			errKind: KindUnexpectedEndOfFile,
+			errLine: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []lexToken{}
+			var err error
+			tokens := lex(strings.NewReader(tt.conf))
+			for {
+				token, ok := tokens.next()
+				if !ok {
+					break
+				}
+				if token.Error != nil {
+					err = token.Error
+					continue
+				}
+				got = append(got, lexToken{token.Value, token.Line, token.IsQuoted})
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokens = %+v, want %+v", got, tt.want)
+			}
+
+			if tt.errKind == "" {
+				if err != nil {
+					t.Errorf("error = %v, want none", err)
+				}
+				return
+			}
+			perr, ok := err.(ParseError)
+			if !ok {
+				t.Fatalf("error = %v, want a ParseError", err)
+			}
+			if perr.Kind() != tt.errKind || perr.Line() == nil || *perr.Line() != tt.errLine {
+				t.Errorf("error = %v (%s), want %s on line %d", perr, perr.Kind(), tt.errKind, tt.errLine)
+			}
+		})
+	}
+}
+
+// benchConfig returns an http block with many server blocks, a few MB large.
+func benchConfig(servers int) string {
+	var sb strings.Builder
+	sb.WriteString("events {\n    worker_connections 1024;\n}\n\nhttp {\n")
+	for i := 0; i < servers; i++ {
+		fmt.Fprintf(&sb, `    server {
+        listen 80;
+        server_name site%d.example.com www.site%d.example.com;
+        root /srv/www/site%d;
+
+        # static files are cached for a day
+        location ~* "\.(css|js|png)$" {
+            expires 1d;
+            add_header Cache-Control "public, max-age=86400";
+        }
+
+        location / {
+            try_files $uri $uri/ /index.php?$args;
+            proxy_set_header Host $host;
+            proxy_pass http://127.0.0.1:%d;
+        }
+    }
+`, i, i, i, 8000+i%1000)
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func BenchmarkLex(b *testing.B) {
+	conf := benchConfig(20000)
+	b.SetBytes(int64(len(conf)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tokens := lex(strings.NewReader(conf))
+		for {
+			if _, ok := tokens.next(); !ok {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkParseString(b *testing.B) {
+	conf := benchConfig(20000)
+	b.SetBytes(int64(len(conf)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		payload, err := ParseString(conf, &ParseOptions{SingleFile: true})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if payload.Status != "ok" {
+			b.Fatal(payload.Errors)
+		}
+	}
+}
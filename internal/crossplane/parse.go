@@ -1,6 +1,7 @@
 package crossplane
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -13,6 +14,8 @@ var dfltFileOpen = func(path string) (io.Reader, error) {
 	return os.Open(path)
 }
 
+var dfltFileGlob = filepath.Glob
+
 var hasMagic = regexp.MustCompile(`[*?[]`)
 
 type blockCtx []string
@@ -22,8 +25,23 @@ func (c blockCtx) key() string {
 }
 
 type fileCtx struct {
+	path  string
+	ctx   blockCtx
+	chain []includeStep
+}
+
+// includeStep is an include directive of the chain of includes a file was
+// reached by.
+type includeStep struct {
+	path string
+	line int
+}
+
+// includeKey identifies an included file by its path and the context it is
+// included from, as its directives are checked against that context.
+type includeKey struct {
 	path string
-	ctx  blockCtx
+	ctx  string
 }
 
 type parser struct {
@@ -31,7 +49,10 @@ type parser struct {
 	options     *ParseOptions
 	handleError func(*Config, error)
 	includes    []fileCtx
-	included    map[string]int
+	included    map[includeKey]int
+	chain       []includeStep
+	open        func(path string) (io.Reader, error)
+	glob        func(pattern string) ([]string, error)
 }
 
 // ParseOptions determine the behavior of an NGINX config parse.
@@ -69,8 +90,15 @@ type ParseOptions struct {
 	// PayloadError struct that's added to the Payload struct's Errors array.
 	ErrorCallback func(error) interface{}
 
-	// If specified, use this alternative to open config files
+	// If specified, use this alternative to open config files, the included
+	// ones too. The reader is closed after parsing if it is an io.Closer.
 	Open func(path string) (io.Reader, error)
+
+	// If specified, use this alternative to list the files matching the
+	// pattern of an include, with the syntax of filepath.Glob. Open and Glob
+	// together let a config be parsed from any file system, e.g. an archive
+	// or a map in memory.
+	Glob func(pattern string) ([]string, error)
 }
 
 // Parse nginx from string
@@ -83,12 +111,15 @@ func ParseString(conf string, options *ParseOptions) (*Payload, error) {
 
 	handleError := func(config *Config, err error) {
 		var line *int
+		var rng *Range
 		if e, ok := err.(ParseError); ok {
 			line = e.line
+			rng = e.rng
 		}
 
-		cerr := ConfigError{Line: line, Error: err.Error()}
-		perr := PayloadError{Line: line, Error: err.Error(), File: config.File}
+		kind := ErrorKind(err)
+		cerr := ConfigError{Line: line, Error: err.Error(), Kind: kind, Range: rng}
+		perr := PayloadError{Line: line, Error: err.Error(), File: config.File, Kind: kind, Range: rng}
 		if options.ErrorCallback != nil {
 			perr.Callback = options.ErrorCallback(err)
 		}
@@ -104,13 +135,24 @@ func ParseString(conf string, options *ParseOptions) (*Payload, error) {
 		options:     options,
 		handleError: handleError,
 		includes:    []fileCtx{{path: "", ctx: blockCtx{}}},
+		included:    map[includeKey]int{{path: "", ctx: blockCtx{}.key()}: 0},
 	}
+	p.useFiles(options)
 
 	for len(p.includes) > 0 {
 		incl := p.includes[0]
 		p.includes = p.includes[1:]
 
-		tokens := lex(strings.NewReader(conf))
+		// the string is the main config, the included files are opened
+		var file io.Reader = strings.NewReader(conf)
+		if len(payload.Config) > 0 {
+			var err error
+			if file, err = p.open(incl.path); err != nil {
+				return nil, err
+			}
+		}
+
+		tokens := lex(file)
 		config := Config{
 			File:   incl.path,
 			Status: "ok",
@@ -118,7 +160,11 @@ func ParseString(conf string, options *ParseOptions) (*Payload, error) {
 			Parsed: []Directive{},
 		}
 
+		p.chain = incl.chain
 		parsed, err := p.parse(&config, tokens, incl.ctx, false)
+		if closer, ok := file.(io.Closer); ok {
+			closer.Close()
+		}
 		if err != nil {
 			if options.StopParsingOnError {
 				return nil, err
@@ -148,12 +194,15 @@ func Parse(filename string, options *ParseOptions) (*Payload, error) {
 
 	handleError := func(config *Config, err error) {
 		var line *int
+		var rng *Range
 		if e, ok := err.(ParseError); ok {
 			line = e.line
+			rng = e.rng
 		}
 
-		cerr := ConfigError{Line: line, Error: err.Error()}
-		perr := PayloadError{Line: line, Error: err.Error(), File: config.File}
+		kind := ErrorKind(err)
+		cerr := ConfigError{Line: line, Error: err.Error(), Kind: kind, Range: rng}
+		perr := PayloadError{Line: line, Error: err.Error(), File: config.File, Kind: kind, Range: rng}
 		if options.ErrorCallback != nil {
 			perr.Callback = options.ErrorCallback(err)
 		}
@@ -171,19 +220,15 @@ func Parse(filename string, options *ParseOptions) (*Payload, error) {
 		options:     options,
 		handleError: handleError,
 		includes:    []fileCtx{{path: filename, ctx: blockCtx{}}},
-		included:    map[string]int{filename: 0},
-	}
-
-	fileOpen := dfltFileOpen
-	if options.Open != nil {
-		fileOpen = options.Open
+		included:    map[includeKey]int{{path: filename, ctx: blockCtx{}.key()}: 0},
 	}
+	p.useFiles(options)
 
 	for len(p.includes) > 0 {
 		incl := p.includes[0]
 		p.includes = p.includes[1:]
 
-		file, err := fileOpen(incl.path)
+		file, err := p.open(incl.path)
 		if err != nil {
 			return nil, err
 		}
@@ -196,7 +241,11 @@ func Parse(filename string, options *ParseOptions) (*Payload, error) {
 			Parsed: []Directive{},
 		}
 
+		p.chain = incl.chain
 		parsed, err := p.parse(&config, tokens, incl.ctx, false)
+		if closer, ok := file.(io.Closer); ok {
+			closer.Close()
+		}
 		if err != nil {
 			if options.StopParsingOnError {
 				return nil, err
@@ -216,17 +265,62 @@ func Parse(filename string, options *ParseOptions) (*Payload, error) {
 	return &payload, nil
 }
 
+// useFiles sets how the parser opens config files and lists the files
+// matching an include, the os functions unless the options replace them.
+func (p *parser) useFiles(options *ParseOptions) {
+	p.open = dfltFileOpen
+	if options.Open != nil {
+		p.open = options.Open
+	}
+
+	p.glob = dfltFileGlob
+	if options.Glob != nil {
+		p.glob = options.Glob
+	}
+}
+
+// cycle returns the error of an include of fname by the statement if fname
+// is the parsed file or a file including it.
+func (p *parser) cycle(parsing *Config, stmt Directive, fname string) (ParseError, bool) {
+	steps := append(append([]includeStep{}, p.chain...), includeStep{parsing.File, stmt.Line})
+	for i, step := range steps {
+		if step.path != fname {
+			continue
+		}
+
+		chain := []string{}
+		for _, step := range steps[i:] {
+			chain = append(chain, fmt.Sprintf("%s:%d", step.path, step.line))
+		}
+		chain = append(chain, fname)
+
+		return ParseError{
+			what:  "include cycle: " + strings.Join(chain, " -> "),
+			kind:  KindIncludeCycle,
+			file:  &parsing.File,
+			line:  &stmt.Line,
+			rng:   &stmt.ArgRanges[0],
+			chain: chain,
+		}, true
+	}
+	return ParseError{}, false
+}
+
 // parse Recursively parses directives from an nginx config context.
-func (p *parser) parse(parsing *Config, tokens chan ngxToken, ctx blockCtx, consume bool) ([]Directive, error) {
+func (p *parser) parse(parsing *Config, tokens *lexer, ctx blockCtx, consume bool) ([]Directive, error) {
 	parsed := []Directive{}
 
 	// parse recursively by pulling from a flat stream of tokens
-	for t := range tokens {
+	for {
+		t, ok := tokens.next()
+		if !ok {
+			break
+		}
 		if t.Error != nil {
 			return nil, t.Error
 		}
 
-		commentsInArgs := []string{}
+		commentsInArgs := []ngxToken{}
 
 		// we are parsing a block, so break if it's closing
 		if t.Value == "}" && !t.IsQuoted {
@@ -243,10 +337,12 @@ func (p *parser) parse(parsing *Config, tokens chan ngxToken, ctx blockCtx, cons
 		}
 
 		// the first token should always be an nginx directive
+		nameRange := t.Range
 		stmt := Directive{
 			Directive: t.Value,
 			Line:      t.Line,
 			Args:      []string{},
+			Range:     &nameRange,
 		}
 
 		// if token is comment
@@ -261,18 +357,19 @@ func (p *parser) parse(parsing *Config, tokens chan ngxToken, ctx blockCtx, cons
 		}
 
 		// parse arguments by reading tokens
-		t = <-tokens
+		t, _ = tokens.next()
 		for t.IsQuoted || (t.Value != "{" && t.Value != ";" && t.Value != "}" && t.Value != "") {
 			if strings.HasPrefix(t.Value, "#") && !t.IsQuoted {
-				commentsInArgs = append(commentsInArgs, t.Value[1:])
+				commentsInArgs = append(commentsInArgs, t)
 			} else {
 				stmt.Args = append(stmt.Args, t.Value)
+				stmt.ArgRanges = append(stmt.ArgRanges, t.Range)
 			}
-			t = <-tokens
+			t, _ = tokens.next()
 		}
 
 		// consume the directive if it is ignored and move on
-		if contains(p.options.IgnoreDirectives, stmt.Directive) {
+		if Contains(p.options.IgnoreDirectives, stmt.Directive) {
 			// if this directive was a block consume it too
 			if t.Value == "{" && !t.IsQuoted {
 				_, _ = p.parse(parsing, tokens, nil, true)
@@ -291,7 +388,7 @@ func (p *parser) parse(parsing *Config, tokens chan ngxToken, ctx blockCtx, cons
 		if perr, ok := err.(ParseError); ok && !p.options.StopParsingOnError {
 			p.handleError(parsing, perr)
 			// if it was a block but shouldn"t have been then consume
-			if strings.HasSuffix(perr.what, ` is not terminated by ";"`) {
+			if perr.kind == KindMissingSemicolon {
 				if t.Value != "}" && !t.IsQuoted {
 					_, _ = p.parse(parsing, tokens, nil, true)
 				} else {
@@ -316,7 +413,7 @@ func (p *parser) parse(parsing *Config, tokens chan ngxToken, ctx blockCtx, cons
 			// get names of all included files
 			var fnames []string
 			if hasMagic.MatchString(pattern) {
-				fnames, err = filepath.Glob(pattern)
+				fnames, err = p.glob(pattern)
 				if err != nil {
 					return nil, err
 				}
@@ -324,11 +421,13 @@ func (p *parser) parse(parsing *Config, tokens chan ngxToken, ctx blockCtx, cons
 			} else {
 				// if the file pattern was explicit, nginx will check
 				// that the included file can be opened and read
-				if f, err := os.Open(pattern); err != nil {
+				if f, err := p.open(pattern); err != nil {
 					perr := ParseError{
 						what: err.Error(),
 						file: &parsing.File,
 						line: &stmt.Line,
+						kind: KindIncludeNotFound,
+						rng:  &stmt.ArgRanges[0],
 					}
 					if !p.options.StopParsingOnError {
 						p.handleError(parsing, perr)
@@ -336,19 +435,36 @@ func (p *parser) parse(parsing *Config, tokens chan ngxToken, ctx blockCtx, cons
 						return nil, perr
 					}
 				} else {
-					f.Close()
+					if closer, ok := f.(io.Closer); ok {
+						closer.Close()
+					}
 					fnames = []string{pattern}
 				}
 			}
 
 			for _, fname := range fnames {
-				// the included set keeps files from being parsed twice
-				// TODO: handle files included from multiple contexts
-				if _, ok := p.included[fname]; !ok {
-					p.included[fname] = len(p.included)
-					p.includes = append(p.includes, fileCtx{fname, ctx})
+				// a file including itself, directly or not, would never end
+				if perr, ok := p.cycle(parsing, stmt, fname); ok {
+					if p.options.StopParsingOnError {
+						return nil, perr
+					}
+					p.handleError(parsing, perr)
+					continue
+				}
+
+				// the included set keeps files from being parsed twice in
+				// the same context, a file included from another context is
+				// parsed again to be checked against it
+				key := includeKey{path: fname, ctx: ctx.key()}
+				if _, ok := p.included[key]; !ok {
+					p.included[key] = len(p.included)
+					p.includes = append(p.includes, fileCtx{
+						path:  fname,
+						ctx:   append(blockCtx{}, ctx...),
+						chain: append(append([]includeStep{}, p.chain...), includeStep{parsing.File, stmt.Line}),
+					})
 				}
-				*stmt.Includes = append(*stmt.Includes, p.included[fname])
+				*stmt.Includes = append(*stmt.Includes, p.included[key])
 			}
 		}
 
@@ -365,13 +481,15 @@ func (p *parser) parse(parsing *Config, tokens chan ngxToken, ctx blockCtx, cons
 		parsed = append(parsed, stmt)
 
 		// add all comments found inside args after stmt is added
-		for _, comment := range commentsInArgs {
-			comment := comment
+		for _, t := range commentsInArgs {
+			comment := t.Value[1:]
+			commentRange := t.Range
 			parsed = append(parsed, Directive{
 				Directive: "#",
 				Line:      stmt.Line,
 				Args:      []string{},
 				Comment:   &comment,
+				Range:     &commentRange,
 			})
 		}
 	}
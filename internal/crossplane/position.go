@@ -0,0 +1,38 @@
+package crossplane
+
+import "unicode/utf8"
+
+// Position is a place in a config file. Line and Column start at 1, the
+// column counts runes, and Offset is the byte offset from the start of the
+// file.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+// Range is the part of a config file a token was read from. End is the
+// position right after its last character, quotes included.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Contains returns true if the position is inside the range.
+func (r Range) Contains(offset int) bool {
+	return r.Start.Offset <= offset && offset < r.End.Offset
+}
+
+// position returns the position of a char.
+func (cl charLine) position() Position {
+	return Position{Line: cl.line, Column: cl.column, Offset: cl.offset}
+}
+
+// after returns the position right after a char.
+func (cl charLine) after() Position {
+	return Position{
+		Line:   cl.line,
+		Column: cl.column + utf8.RuneCountInString(cl.char),
+		Offset: cl.offset + len(cl.char),
+	}
+}
@@ -0,0 +1,182 @@
+package crossplane
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DirectiveInfo describes where a known directive is allowed and how many
+// arguments it takes, as analyze checks it.
+type DirectiveInfo struct {
+	Name     string
+	Contexts [][]string
+	Block    bool
+	Flag     bool
+	Args     []string
+}
+
+// Cursor is what comes before a position of a config, e.g. to complete the
+// directive being typed.
+type Cursor struct {
+	// Context is the block context of the position, e.g. [http server].
+	Context []string
+	// Statement holds the complete tokens of the statement before the
+	// position, it is empty while the directive name is typed.
+	Statement []string
+	// Word is the part of the token the position is in, up to the position.
+	Word string
+}
+
+// LookupDirective returns how a directive is analyzed, false if it is not
+// known.
+func LookupDirective(name string) (DirectiveInfo, bool) {
+	masks, ok := directives[name]
+	if !ok {
+		return DirectiveInfo{}, false
+	}
+
+	info := DirectiveInfo{Name: name}
+	keys := sortedContexts()
+	for _, mask := range masks {
+		for _, key := range keys {
+			if mask&contexts[key] != 0 {
+				info.Contexts = append(info.Contexts, splitContext(key))
+			}
+		}
+		if mask&ngxConfBlock != 0 {
+			info.Block = true
+		}
+		if mask&ngxConfFlag != 0 {
+			info.Flag = true
+		}
+		args := describeArgs(mask)
+		if !Contains(info.Args, args) {
+			info.Args = append(info.Args, args)
+		}
+	}
+
+	return info, true
+}
+
+// DirectivesAllowed returns the sorted names of the directives allowed in a
+// block context, none if the context is not known.
+func DirectivesAllowed(ctx []string) []string {
+	currCtx, ok := contexts[blockCtx(ctx).key()]
+	if !ok {
+		return []string{}
+	}
+
+	names := []string{}
+	for name, masks := range directives {
+		for _, mask := range masks {
+			if mask&currCtx != 0 {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Directives returns the sorted names of every known directive.
+func Directives() []string {
+	names := make([]string, 0, len(directives))
+	for name := range directives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CursorAt returns what comes before the byte offset of a config, whose
+// directives are in the given context, e.g. [http] for a file included from
+// the http block.
+func CursorAt(conf string, offset int, ctx []string) Cursor {
+	cursor := Cursor{Context: []string{}, Statement: []string{}}
+	stack := []blockCtx{append(blockCtx{}, ctx...)}
+
+	tokens := lex(strings.NewReader(conf))
+	for {
+		t, ok := tokens.next()
+		if !ok || t.Error != nil || t.Range.Start.Offset >= offset {
+			break
+		}
+
+		special := !t.IsQuoted && (t.Value == "{" || t.Value == "}" || t.Value == ";")
+		if t.Range.End.Offset >= offset && !special {
+			cursor.Word = conf[t.Range.Start.Offset:offset]
+			break
+		}
+
+		switch {
+		case t.IsQuoted:
+			cursor.Statement = append(cursor.Statement, t.Value)
+		case strings.HasPrefix(t.Value, "#"):
+		case !special:
+			cursor.Statement = append(cursor.Statement, t.Value)
+		case t.Value == "{":
+			ctx := append(blockCtx{}, stack[len(stack)-1]...)
+			if len(cursor.Statement) > 0 {
+				ctx = enterBlockCtx(Directive{Directive: cursor.Statement[0]}, ctx)
+			}
+			stack = append(stack, ctx)
+			cursor.Statement = []string{}
+		case t.Value == "}":
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+			cursor.Statement = []string{}
+		default:
+			cursor.Statement = []string{}
+		}
+	}
+
+	cursor.Context = append(cursor.Context, stack[len(stack)-1]...)
+	return cursor
+}
+
+// sortedContexts returns the keys of the known contexts in the order of
+// their bits.
+func sortedContexts() []string {
+	keys := make([]string, 0, len(contexts))
+	for key := range contexts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return uint(contexts[keys[i]]) < uint(contexts[keys[j]])
+	})
+	return keys
+}
+
+func splitContext(key string) []string {
+	if key == "" {
+		return []string{"main"}
+	}
+	return strings.Split(key, ">")
+}
+
+func describeArgs(mask int) string {
+	switch {
+	case mask&ngxConfFlag != 0:
+		return "on | off"
+	case mask&ngxConfAny != 0:
+		return "any number"
+	case mask&ngxConf1More != 0:
+		return "1 or more"
+	case mask&ngxConf2More != 0:
+		return "2 or more"
+	}
+
+	counts := []string{}
+	for n := 0; n <= 7; n++ {
+		if mask>>n&1 != 0 {
+			counts = append(counts, fmt.Sprint(n))
+		}
+	}
+	if len(counts) == 0 {
+		return "none"
+	}
+	return strings.Join(counts, " or ")
+}
@@ -1,5 +1,10 @@
 package crossplane
 
+import (
+	"fmt"
+	"strings"
+)
+
 type Payload struct {
 	Status string         `json:"status"`
 	Errors []PayloadError `json:"errors"`
@@ -10,6 +15,8 @@ type PayloadError struct {
 	File     string      `json:"file"`
 	Line     *int        `json:"line"`
 	Error    string      `json:"error"`
+	Kind     string      `json:"kind"`
+	Range    *Range      `json:"range,omitempty"`
 	Callback interface{} `json:"callback,omitempty"`
 }
 
@@ -20,9 +27,20 @@ type Config struct {
 	Parsed []Directive   `json:"parsed"`
 }
 
+// Message returns the error without the file and line it was found at.
+func (e PayloadError) Message() string {
+	if e.Line == nil {
+		return e.Error
+	}
+	message := strings.TrimSuffix(e.Error, fmt.Sprintf(" in %s:%d", e.File, *e.Line))
+	return strings.TrimSuffix(message, fmt.Sprintf(" in %d", *e.Line))
+}
+
 type ConfigError struct {
 	Line  *int   `json:"line"`
 	Error string `json:"error"`
+	Kind  string `json:"kind"`
+	Range *Range `json:"range,omitempty"`
 }
 
 type Directive struct {
@@ -32,6 +50,11 @@ type Directive struct {
 	Includes  *[]int       `json:"includes,omitempty"`
 	Block     *[]Directive `json:"block,omitempty"`
 	Comment   *string      `json:"comment,omitempty"`
+
+	// Range is the range of the directive name, ArgRanges the range of
+	// every argument. They are only known for parsed directives.
+	Range     *Range  `json:"range,omitempty"`
+	ArgRanges []Range `json:"arg_ranges,omitempty"`
 }
 
 // IsBlock returns true if this is a block directive.
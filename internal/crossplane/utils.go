@@ -1,17 +1,12 @@
 package crossplane
 
 import (
-	"fmt"
 	"strings"
 	"unicode"
 )
 
-type included struct {
-	directive Directive
-	err       error
-}
-
-func contains(xs []string, x string) bool {
+// Contains reports whether x is one of xs.
+func Contains(xs []string, x string) bool {
 	for _, s := range xs {
 		if s == x {
 			return true
@@ -24,20 +19,6 @@ func isSpace(s string) bool {
 	return len(strings.TrimSpace(s)) == 0
 }
 
-// TODO: use me later
-// func repr(s string) string {
-// 	q := fmt.Sprintf("%q", s)
-// 	for _, char := range s {
-// 		if char == '"' {
-// 			q = strings.ReplaceAll(q, `\"`, `"`)
-// 			q = strings.ReplaceAll(q, `'`, `\'`)
-// 			q = `'` + q[1:len(q)-1] + `'`
-// 			return q
-// 		}
-// 	}
-// 	return q
-// }
-
 func validFlag(s string) bool {
 	l := strings.ToLower(s)
 	return l == "on" || l == "off"
@@ -46,15 +27,37 @@ func validFlag(s string) bool {
 // prepareIfArgs removes parentheses from an `if` directive's arguments.
 func prepareIfArgs(d Directive) Directive {
 	e := len(d.Args) - 1
+	ranged := len(d.ArgRanges) == len(d.Args)
 	if len(d.Args) > 0 && strings.HasPrefix(d.Args[0], "(") && strings.HasSuffix(d.Args[e], ")") {
+		first, last := d.Args[0], d.Args[e]
 		d.Args[0] = strings.TrimLeftFunc(strings.TrimPrefix(d.Args[0], "("), unicode.IsSpace)
 		d.Args[e] = strings.TrimRightFunc(strings.TrimSuffix(d.Args[e], ")"), unicode.IsSpace)
+		if ranged {
+			// the parentheses and spaces are ascii, so they take one column
+			// and one byte each
+			d.ArgRanges = append([]Range{}, d.ArgRanges...)
+			trimmed := len(first) - len(d.Args[0])
+			d.ArgRanges[0].Start.Column += trimmed
+			d.ArgRanges[0].Start.Offset += trimmed
+			if e == 0 {
+				last = first[trimmed:]
+			}
+			trimmed = len(last) - len(d.Args[e])
+			d.ArgRanges[e].End.Column -= trimmed
+			d.ArgRanges[e].End.Offset -= trimmed
+		}
 		if len(d.Args[0]) == 0 {
 			d.Args = d.Args[1:]
+			if ranged {
+				d.ArgRanges = d.ArgRanges[1:]
+			}
 			e -= 1
 		}
 		if len(d.Args[e]) == 0 {
 			d.Args = d.Args[:e]
+			if ranged {
+				d.ArgRanges = d.ArgRanges[:e]
+			}
 		}
 	}
 	return d
@@ -90,12 +93,11 @@ func combineConfigs(old Payload) (*Payload, error) {
 		}
 	}
 
-	for incl := range performIncludes(old, combined.File, old.Config[0].Parsed) {
-		if incl.err != nil {
-			return nil, incl.err
-		}
-		combined.Parsed = append(combined.Parsed, incl.directive)
+	inlined, err := Inline(&old)
+	if err != nil {
+		return nil, err
 	}
+	combined.Parsed = inlined.Parsed
 
 	return &Payload{
 		Status: status,
@@ -103,47 +105,3 @@ func combineConfigs(old Payload) (*Payload, error) {
 		Config: []Config{combined},
 	}, nil
 }
-
-func performIncludes(old Payload, fromfile string, block []Directive) chan included {
-	c := make(chan included)
-	go func() {
-		defer close(c)
-
-		for _, dir := range block {
-			if dir.IsBlock() {
-				block := []Directive{}
-				for incl := range performIncludes(old, fromfile, *dir.Block) {
-					if incl.err != nil {
-						c <- incl
-						return
-					}
-					block = append(block, incl.directive)
-				}
-				dir.Block = &block
-			}
-
-			if !dir.IsInclude() {
-				c <- included{directive: dir}
-				continue
-			}
-
-			for _, idx := range *dir.Includes {
-				if idx >= len(old.Config) {
-					c <- included{
-						err: ParseError{
-							what: fmt.Sprintf("include config with index: %d", idx),
-							file: &fromfile,
-							line: &dir.Line,
-						},
-					}
-					return
-				}
-				for incl := range performIncludes(old, old.Config[idx].File, old.Config[idx].Parsed) {
-					c <- incl
-				}
-			}
-		}
-
-	}()
-	return c
-}
@@ -0,0 +1,215 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// number of unchanged lines shown around each change
+const contextLines = 3
+
+// marker written after a last line with no newline, as diff -u does
+const noNewline = "\\ No newline at end of file"
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns a unified diff between two texts, or an empty string if
+// they are the same.
+func Unified(fromName string, toName string, from string, to string) string {
+	if from == to {
+		return ""
+	}
+
+	ops := diffLines(splitLines(from), splitLines(to))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+
+	// group the ops into hunks with some unchanged lines around them
+	for start := 0; start < len(ops); {
+		for start < len(ops) && ops[start].kind == opEqual {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		first := start - contextLines
+		if first < 0 {
+			first = 0
+		}
+
+		last := start
+		equals := 0
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != opEqual {
+				last = i
+				equals = 0
+				continue
+			}
+			equals++
+			if equals > contextLines*2 {
+				break
+			}
+		}
+
+		end := last + contextLines + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		writeHunk(&sb, ops, first, end)
+		start = end
+	}
+
+	return sb.String()
+}
+
+func writeHunk(sb *strings.Builder, ops []op, first int, end int) {
+	// line numbers of the hunk start in both texts
+	fromLine, toLine := 1, 1
+	for _, o := range ops[:first] {
+		if o.kind != opInsert {
+			fromLine++
+		}
+		if o.kind != opDelete {
+			toLine++
+		}
+	}
+
+	fromCount, toCount := 0, 0
+	for _, o := range ops[first:end] {
+		if o.kind != opInsert {
+			fromCount++
+		}
+		if o.kind != opDelete {
+			toCount++
+		}
+	}
+
+	if fromCount == 0 {
+		fromLine--
+	}
+	if toCount == 0 {
+		toLine--
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", fromLine, fromCount, toLine, toCount)
+	for _, o := range ops[first:end] {
+		switch o.kind {
+		case opEqual:
+			sb.WriteString(" ")
+		case opDelete:
+			sb.WriteString("-")
+		case opInsert:
+			sb.WriteString("+")
+		}
+		sb.WriteString(o.line + "\n")
+	}
+}
+
+// splitLines splits a text in lines, a last line with no newline keeps the
+// marker so that it differs from the same line with one.
+func splitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	if strings.HasSuffix(s, "\n") {
+		return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	}
+	lines := strings.Split(s, "\n")
+	lines[len(lines)-1] += "\n" + noNewline
+	return lines
+}
+
+// diffLines finds the shortest edit script between a and b using the
+// Myers algorithm.
+func diffLines(a []string, b []string) []op {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	trace := [][]int{}
+
+	for d := 0; d <= max; d++ {
+		// only the diagonals reachable with d edits are ever read back
+		snapshot := make([]int, 2*d+3)
+		copy(snapshot, v[offset-d-1:offset+d+2])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, d)
+			}
+		}
+	}
+
+	return nil
+}
+
+// backtrack walks the saved frontiers back from the end to build the ops.
+func backtrack(a []string, b []string, trace [][]int, depth int) []op {
+	x, y := len(a), len(b)
+	ops := []op{}
+
+	for d := depth; d >= 0; d-- {
+		v := trace[d]
+		offset := d + 1
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, op{kind: opEqual, line: a[x]})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				y--
+				ops = append(ops, op{kind: opInsert, line: b[y]})
+			} else {
+				x--
+				ops = append(ops, op{kind: opDelete, line: a[x]})
+			}
+		}
+	}
+
+	// reverse since the ops were collected from the end
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
@@ -0,0 +1,77 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want string
+	}{
+		{
+			name: "same",
+			from: "a\nb\n",
+			to:   "a\nb\n",
+			want: "",
+		},
+		{
+			name: "changed line",
+			from: "a\nb\nc\n",
+			to:   "a\nB\nc\n",
+			want: "--- old\n+++ new\n" +
+				"@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "insert into empty",
+			from: "",
+			to:   "a\n",
+			want: "--- old\n+++ new\n" +
+				"@@ -0,0 +1,1 @@\n+a\n",
+		},
+		{
+			name: "delete all",
+			from: "a\nb\n",
+			to:   "",
+			want: "--- old\n+++ new\n" +
+				"@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "context is limited",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n",
+			to:   "1\n2\n3\n4\n5\n6\n7\nx\n",
+			want: "--- old\n+++ new\n" +
+				"@@ -5,4 +5,4 @@\n 5\n 6\n 7\n-8\n+x\n",
+		},
+		{
+			name: "distant changes are split in hunks",
+			from: "a\n1\n2\n3\n4\n5\n6\n7\nb\n",
+			to:   "A\n1\n2\n3\n4\n5\n6\n7\nB\n",
+			want: "--- old\n+++ new\n" +
+				"@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n" +
+				"@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+		},
+		{
+			name: "missing newline at end",
+			from: "a\nb",
+			to:   "a\nb\n",
+			want: "--- old\n+++ new\n" +
+				"@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "missing newline in context",
+			from: "a\nb",
+			to:   "A\nb",
+			want: "--- old\n+++ new\n" +
+				"@@ -1,2 +1,2 @@\n-a\n+A\n b\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("old", "new", tt.from, tt.to); got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
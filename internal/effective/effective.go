@@ -0,0 +1,216 @@
+package effective
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/internal/matcher"
+)
+
+// directives that only apply to the block they are in, like the handlers
+// and the rewrite module directives
+var notInherited = map[string]bool{
+	"location":       true,
+	"if":             true,
+	"limit_except":   true,
+	"server":         true,
+	"server_name":    true,
+	"listen":         true,
+	"upstream":       true,
+	"map":            true,
+	"geo":            true,
+	"split_clients":  true,
+	"rewrite":        true,
+	"return":         true,
+	"set":            true,
+	"break":          true,
+	"try_files":      true,
+	"internal":       true,
+	"proxy_pass":     true,
+	"grpc_pass":      true,
+	"fastcgi_pass":   true,
+	"uwsgi_pass":     true,
+	"scgi_pass":      true,
+	"memcached_pass": true,
+	"stub_status":    true,
+	"empty_gif":      true,
+}
+
+// directives sharing a single setting, a level defining any of them replaces
+// all of them
+var groups = map[string]string{
+	"allow": "allow/deny",
+	"deny":  "allow/deny",
+	"root":  "root/alias",
+	"alias": "root/alias",
+}
+
+// Level is a block the location inherits directives from.
+type Level struct {
+	// e.g. "http", "server" or "location ^~ /static/"
+	Name      string
+	Directive crossplane.Directive
+	File      string
+}
+
+// Value is a directive found at a level.
+type Value struct {
+	Directive crossplane.Directive
+	Level     string
+	File      string
+}
+
+// Setting is the effective value of a directive. nginx never merges the
+// values of a parent with the ones of a child: the innermost level defining
+// a directive wins, which for array directives like add_header or
+// proxy_set_header means the values of the outer levels are all dropped.
+type Setting struct {
+	// Directive name, or the group name for directives sharing an array.
+	Name     string
+	Values   []Value
+	Replaced []Value
+}
+
+type Config struct {
+	Match    *matcher.LocationMatcher
+	Levels   []Level
+	Settings []Setting
+}
+
+// ForRequest computes the effective configuration of the location handling
+// the url, after following internal redirects.
+func ForRequest(conf *crossplane.Payload, targetUrl string, opts *matcher.MatchOptions) (*Config, error) {
+	if conf == nil {
+		return nil, crossplane.ErrNoConfig
+	}
+
+	if opts == nil {
+		opts = &matcher.MatchOptions{}
+	}
+
+	parsedUrl, err := url.Parse(targetUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, err := matcher.NewScope(conf, parsedUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := scope.Follow(parsedUrl, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return Compute(scope, match), nil
+}
+
+// Compute computes the effective configuration of a location matched in the
+// scope, from the http block down to the innermost nested location.
+func Compute(scope *matcher.Scope, match *matcher.LocationMatcher) *Config {
+	config := &Config{
+		Match:    match,
+		Levels:   levels(scope, match),
+		Settings: []Setting{},
+	}
+
+	// the directives of the matched location apply to it even if they are
+	// not inherited, only its nested locations are other blocks
+	innermost := -1
+	if match != nil && len(match.Chain) > 0 {
+		innermost = len(config.Levels) - 1
+	}
+
+	index := map[string]int{}
+	for l, level := range config.Levels {
+		block := level.Directive.Block
+		if block == nil {
+			continue
+		}
+
+		defined := map[string]bool{}
+		for i, d := range *block {
+			if d.IsComment() || d.Directive == "location" {
+				continue
+			}
+			if l != innermost && notInherited[d.Directive] {
+				continue
+			}
+
+			name := d.Directive
+			if group, ok := groups[name]; ok {
+				name = group
+			}
+
+			idx, ok := index[name]
+			if !ok {
+				idx = len(config.Settings)
+				index[name] = idx
+				config.Settings = append(config.Settings, Setting{Name: name})
+			}
+			setting := &config.Settings[idx]
+
+			// the first definition at a level replaces every inherited value
+			if !defined[name] {
+				defined[name] = true
+				setting.Replaced = append(setting.Replaced, setting.Values...)
+				setting.Values = nil
+			}
+
+			setting.Values = append(setting.Values, Value{
+				Directive: d,
+				Level:     level.Name,
+				File:      scope.DirectiveFile(block, i),
+			})
+		}
+	}
+
+	return config
+}
+
+// levels returns the http, server and location blocks of a match, from the
+// outermost one.
+func levels(scope *matcher.Scope, match *matcher.LocationMatcher) []Level {
+	levels := []Level{}
+
+	parents := scope.Parents()
+	for i := len(parents) - 1; i >= 0; i-- {
+		parent := parents[i]
+		levels = append(levels, Level{
+			Name:      parent.Directive,
+			Directive: parent,
+			File:      scope.File(parent.Block),
+		})
+	}
+
+	if match == nil {
+		return levels
+	}
+
+	for _, location := range match.Chain {
+		levels = append(levels, Level{
+			Name:      strings.Join(strings.Fields("location "+location.MatchModifer+" "+location.MatchPath), " "),
+			Directive: location.Directives,
+			File:      scope.File(location.Directives.Block),
+		})
+	}
+
+	return levels
+}
+
+// Lookup returns the effective setting of a directive, or nil if no level
+// defines it.
+func (c *Config) Lookup(name string) *Setting {
+	if group, ok := groups[name]; ok {
+		name = group
+	}
+
+	for i, setting := range c.Settings {
+		if setting.Name == name {
+			return &c.Settings[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package lint
+
+import (
+	"sort"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Finding is an issue a rule found in the config.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Message  string   `json:"message"`
+}
+
+// Rule checks the config for a kind of issue. Rules of your own can be
+// registered on a Linter next to the default ones.
+type Rule interface {
+	// ID identifies the rule in findings, e.g. "if-in-location".
+	ID() string
+	Description() string
+	Check(tree *Tree) []Finding
+}
+
+type Linter struct {
+	rules []Rule
+}
+
+// NewLinter returns a linter running the given rules, or the default rules
+// if none is given.
+func NewLinter(rules ...Rule) *Linter {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+	return &Linter{rules: rules}
+}
+
+// Register adds a rule to the linter.
+func (l *Linter) Register(rule Rule) {
+	l.rules = append(l.rules, rule)
+}
+
+// Rules returns the rules the linter runs.
+func (l *Linter) Rules() []Rule {
+	return l.rules
+}
+
+// Lint runs every rule on the config, findings are sorted by file and line.
+func (l *Linter) Lint(conf *crossplane.Payload) ([]Finding, error) {
+	if conf == nil {
+		return nil, crossplane.ErrNoConfig
+	}
+
+	tree, err := NewTree(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []Finding{}
+	for _, rule := range l.rules {
+		for _, finding := range rule.Check(tree) {
+			if finding.Rule == "" {
+				finding.Rule = rule.ID()
+			}
+			findings = append(findings, finding)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+
+	return findings, nil
+}
+
+// DefaultRules returns the rules run when no rule is given.
+func DefaultRules() []Rule {
+	return []Rule{
+		addHeaderInheritance{},
+		aliasTrailingSlash{},
+		ifInLocation{},
+		serverTokens{},
+		duplicateServer{},
+		regexShadowing{},
+	}
+}
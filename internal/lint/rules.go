@@ -0,0 +1,398 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adityals/go-ngx-config/internal/pcre"
+)
+
+// addHeaderInheritance reports add_header directives dropping the ones of a
+// parent block, since a block defining any add_header inherits none.
+type addHeaderInheritance struct{}
+
+func (addHeaderInheritance) ID() string { return "add-header-inheritance" }
+
+func (addHeaderInheritance) Description() string {
+	return "add_header in a block drops every add_header inherited from its parents"
+}
+
+func (addHeaderInheritance) Check(tree *Tree) []Finding {
+	findings := []Finding{}
+
+	tree.Walk(func(n *Node) {
+		if !inheritsHeaders(n) {
+			return
+		}
+
+		headers := n.Find("add_header")
+		if len(headers) == 0 {
+			return
+		}
+
+		for parent := n.Parent; parent != nil; parent = parent.Parent {
+			inherited := parent.Find("add_header")
+			if len(inherited) == 0 {
+				continue
+			}
+
+			findings = append(findings, headers[0].Finding(SeverityWarning, fmt.Sprintf(
+				"add_header in %s drops the %d add_header directive(s) of %s at line %d, repeat them here if they are still needed",
+				describe(n), len(inherited), describe(parent), inherited[0].Line,
+			)))
+			return
+		}
+	})
+
+	return findings
+}
+
+func inheritsHeaders(n *Node) bool {
+	switch n.Name() {
+	case "server", "location", "if":
+		return n.Inside("http") != nil
+	}
+	return false
+}
+
+// aliasTrailingSlash reports prefix locations and their alias disagreeing on
+// the trailing slash, the classic path traversal of "location /img" with
+// "alias /data/img/" serving /img../secret.
+type aliasTrailingSlash struct{}
+
+func (aliasTrailingSlash) ID() string { return "alias-trailing-slash" }
+
+func (aliasTrailingSlash) Description() string {
+	return "alias of a prefix location must end with a slash if and only if the location does"
+}
+
+func (aliasTrailingSlash) Check(tree *Tree) []Finding {
+	findings := []Finding{}
+
+	tree.Walk(func(n *Node) {
+		if n.Name() != "location" {
+			return
+		}
+
+		modifier, path := locationArgs(n)
+		if (modifier != "" && modifier != "^~") || strings.HasPrefix(path, "@") {
+			return
+		}
+
+		for _, alias := range n.Find("alias") {
+			if len(alias.Args) == 0 {
+				continue
+			}
+
+			target := alias.Args[0]
+			switch {
+			case !strings.HasSuffix(path, "/") && strings.HasSuffix(target, "/"):
+				findings = append(findings, alias.Finding(SeverityError, fmt.Sprintf(
+					"location %s has no trailing slash but alias %s has one, %s../ escapes the alias directory",
+					path, target, path,
+				)))
+			case strings.HasSuffix(path, "/") && !strings.HasSuffix(target, "/"):
+				findings = append(findings, alias.Finding(SeverityWarning, fmt.Sprintf(
+					"location %s has a trailing slash but alias %s has none, %sfile is served from %sfile",
+					path, target, path, target,
+				)))
+			}
+		}
+	})
+
+	return findings
+}
+
+// ifInLocation reports if blocks inside of locations doing more than return
+// or rewrite ... last, the only things that are safe there.
+type ifInLocation struct{}
+
+func (ifInLocation) ID() string { return "if-in-location" }
+
+func (ifInLocation) Description() string {
+	return "only return and rewrite ... last are safe inside if in a location"
+}
+
+func (ifInLocation) Check(tree *Tree) []Finding {
+	findings := []Finding{}
+
+	tree.Walk(func(n *Node) {
+		if n.Name() != "if" || n.Parent == nil || n.Parent.Name() != "location" {
+			return
+		}
+
+		for _, child := range n.Children {
+			if child.Name() == "return" {
+				continue
+			}
+			if child.Name() == "rewrite" && len(child.Args) == 3 && child.Args[2] == "last" {
+				continue
+			}
+
+			findings = append(findings, child.Finding(SeverityWarning, fmt.Sprintf(
+				"%q inside if in %s may not work as expected, only return and rewrite ... last are safe",
+				child.Name(), describe(n.Parent),
+			)))
+			return
+		}
+	})
+
+	return findings
+}
+
+// serverTokens reports servers sending the nginx version since
+// server_tokens isn't turned off.
+type serverTokens struct{}
+
+func (serverTokens) ID() string { return "server-tokens" }
+
+func (serverTokens) Description() string {
+	return "server_tokens off hides the nginx version from responses and error pages"
+}
+
+func (serverTokens) Check(tree *Tree) []Finding {
+	findings := []Finding{}
+
+	tree.Walk(func(n *Node) {
+		if n.Name() != "http" || n.Has("server_tokens", "off") {
+			return
+		}
+
+		servers := servers(n)
+		if len(servers) == 0 {
+			findings = append(findings, n.Finding(SeverityInfo, "server_tokens off is missing"))
+			return
+		}
+
+		for _, server := range servers {
+			if !server.Has("server_tokens", "off") {
+				findings = append(findings, server.Finding(SeverityInfo, fmt.Sprintf(
+					"server_tokens off is missing, %s sends the nginx version", describe(server),
+				)))
+			}
+		}
+	})
+
+	return findings
+}
+
+// duplicateServer reports servers with the same name on the same address,
+// which nginx ignores, and duplicate default servers.
+type duplicateServer struct{}
+
+func (duplicateServer) ID() string { return "duplicate-server" }
+
+func (duplicateServer) Description() string {
+	return "a server name can only be used once per listen address"
+}
+
+func (duplicateServer) Check(tree *Tree) []Finding {
+	findings := []Finding{}
+
+	tree.Walk(func(n *Node) {
+		if n.Name() != "http" {
+			return
+		}
+
+		names := map[string]*Node{}
+		defaults := map[string]*Node{}
+
+		for _, server := range servers(n) {
+			for _, listen := range listens(server) {
+				if listen.defaultServer {
+					if first, ok := defaults[listen.addr]; ok {
+						findings = append(findings, listen.node.Finding(SeverityError, fmt.Sprintf(
+							"a duplicate default server for %s, the first one is at %s:%d",
+							listen.addr, first.File, first.Line,
+						)))
+					} else {
+						defaults[listen.addr] = listen.node
+					}
+				}
+
+				for _, name := range serverNames(server) {
+					key := listen.addr + " " + name
+					if first, ok := names[key]; ok {
+						findings = append(findings, server.Finding(SeverityWarning, fmt.Sprintf(
+							"conflicting server name %q on %s is ignored, it is already used at %s:%d",
+							name, listen.addr, first.File, first.Line,
+						)))
+						continue
+					}
+					names[key] = server
+				}
+			}
+		}
+	})
+
+	return findings
+}
+
+type listen struct {
+	addr          string
+	defaultServer bool
+	node          *Node
+}
+
+// listens returns the addresses a server listens on, servers without listen
+// are on *:80.
+func listens(server *Node) []listen {
+	found := []listen{}
+
+	for _, d := range server.Find("listen") {
+		if len(d.Args) == 0 || strings.HasPrefix(d.Args[0], "unix:") {
+			continue
+		}
+
+		addr := d.Args[0]
+		if isNumber(addr) {
+			addr = "*:" + addr
+		} else if idx := strings.LastIndex(addr, ":"); idx < 0 || strings.HasSuffix(addr, "]") {
+			addr += ":80"
+		}
+		addr = strings.Replace(addr, "0.0.0.0:", "*:", 1)
+
+		defaultServer := false
+		for _, arg := range d.Args[1:] {
+			if arg == "default_server" || arg == "default" {
+				defaultServer = true
+			}
+		}
+
+		found = append(found, listen{addr: addr, defaultServer: defaultServer, node: d})
+	}
+
+	if len(found) == 0 {
+		found = append(found, listen{addr: "*:80", node: server})
+	}
+
+	return found
+}
+
+func isNumber(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func serverNames(server *Node) []string {
+	names := []string{}
+	for _, d := range server.Find("server_name") {
+		for _, name := range d.Args {
+			if !strings.HasPrefix(name, "~") {
+				name = strings.ToLower(name)
+			}
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		names = append(names, "")
+	}
+	return names
+}
+
+// regexShadowing reports prefix locations whose own path is matched by a
+// regex location of the same block, which wins over them.
+type regexShadowing struct{}
+
+func (regexShadowing) ID() string { return "regex-shadowing" }
+
+func (regexShadowing) Description() string {
+	return "regex locations are checked after prefix locations and win over them"
+}
+
+func (regexShadowing) Check(tree *Tree) []Finding {
+	findings := []Finding{}
+
+	check := func(n *Node) {
+		locations := n.Find("location")
+
+		for _, prefix := range locations {
+			modifier, path := locationArgs(prefix)
+			if modifier != "" || strings.HasPrefix(path, "@") {
+				continue
+			}
+
+			for _, location := range locations {
+				modifier, expr := locationArgs(location)
+				if modifier != "~" && modifier != "~*" {
+					continue
+				}
+
+				reg, err := pcre.Compile(expr, modifier == "~*")
+				if err != nil {
+					continue
+				}
+
+				// a path the regex gives up on is neither shadowed nor not
+				shadowed, err := reg.MatchString(path)
+				if err != nil {
+					findings = append(findings, prefix.Finding(SeverityInfo, fmt.Sprintf(
+						"cannot tell whether location %s is shadowed by location %s %s at line %d: %v",
+						path, modifier, expr, location.Line, err,
+					)))
+					continue
+				}
+				if !shadowed {
+					continue
+				}
+
+				findings = append(findings, prefix.Finding(SeverityWarning, fmt.Sprintf(
+					"location %s is shadowed by location %s %s at line %d, use ^~ to keep the prefix location",
+					path, modifier, expr, location.Line,
+				)))
+				break
+			}
+		}
+	}
+
+	tree.Walk(func(n *Node) {
+		if n.Name() == "server" || n.Name() == "location" {
+			check(n)
+		}
+	})
+
+	return findings
+}
+
+// servers returns the server blocks of an http block.
+func servers(http *Node) []*Node {
+	found := []*Node{}
+	http.walk(func(n *Node) {
+		// upstream blocks have server directives too
+		if n.Name() == "server" && n.Block != nil && n.Inside("http") == http {
+			found = append(found, n)
+		}
+	})
+	return found
+}
+
+func locationArgs(n *Node) (string, string) {
+	switch len(n.Args) {
+	case 1:
+		return "", n.Args[0]
+	case 2:
+		return n.Args[0], n.Args[1]
+	}
+	return "", ""
+}
+
+// describe names a block in messages, e.g. "location ~ \.php$".
+func describe(n *Node) string {
+	if n.Name() == "server" {
+		for _, name := range serverNames(n) {
+			if name != "" {
+				return "server " + name
+			}
+		}
+	}
+
+	if len(n.Args) == 0 {
+		return n.Name()
+	}
+	return n.Name() + " " + strings.Join(n.Args, " ")
+}
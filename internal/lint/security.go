@@ -0,0 +1,472 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SecurityRules returns the rules auditing TLS, response headers and access
+// control.
+func SecurityRules() []Rule {
+	return []Rule{
+		weakSSLProtocols{},
+		weakSSLCiphers{},
+		sslPreferServerCiphers{},
+		missingSecurityHeaders{},
+		autoindexOn{},
+		proxyPassSSRF{},
+		allowAllBeforeDeny{},
+		serverTokensOn{},
+	}
+}
+
+// weakSSLProtocols reports protocols older than TLSv1.2.
+type weakSSLProtocols struct{}
+
+func (weakSSLProtocols) ID() string { return "weak-ssl-protocols" }
+
+func (weakSSLProtocols) Description() string {
+	return "ssl_protocols must not enable SSLv2, SSLv3, TLSv1 or TLSv1.1"
+}
+
+func (weakSSLProtocols) Check(tree *Tree) []Finding {
+	findings := []Finding{}
+
+	tree.Walk(func(n *Node) {
+		if n.Name() != "ssl_protocols" {
+			return
+		}
+
+		weak := []string{}
+		for _, protocol := range n.Args {
+			switch protocol {
+			case "SSLv2", "SSLv3", "TLSv1", "TLSv1.1":
+				weak = append(weak, protocol)
+			}
+		}
+
+		if len(weak) > 0 {
+			findings = append(findings, n.Finding(SeverityError, fmt.Sprintf(
+				"ssl_protocols enables the deprecated %s, use TLSv1.2 and TLSv1.3 only", strings.Join(weak, ", "),
+			)))
+		}
+	})
+
+	return findings
+}
+
+// weakSSLCiphers reports cipher suites that are broken or have no
+// authentication or encryption.
+type weakSSLCiphers struct{}
+
+// parts of OpenSSL cipher names and aliases that are weak
+var weakCiphers = []string{"NULL", "EXPORT", "EXP", "RC4", "DES", "3DES", "MD5", "ADH", "AECDH", "RC2", "IDEA", "SEED", "PSK", "SRP"}
+
+func (weakSSLCiphers) ID() string { return "weak-ssl-ciphers" }
+
+func (weakSSLCiphers) Description() string {
+	return "ssl_ciphers must not enable NULL, EXPORT, RC4, DES, 3DES, MD5 or anonymous ciphers"
+}
+
+func (weakSSLCiphers) Check(tree *Tree) []Finding {
+	findings := []Finding{}
+
+	tree.Walk(func(n *Node) {
+		if n.Name() != "ssl_ciphers" || len(n.Args) == 0 {
+			return
+		}
+
+		weak := []string{}
+		for _, cipher := range strings.Split(n.Args[0], ":") {
+			// excluded ciphers are fine
+			if cipher == "" || strings.HasPrefix(cipher, "!") || strings.HasPrefix(cipher, "-") {
+				continue
+			}
+			if isWeakCipher(strings.TrimPrefix(cipher, "+")) {
+				weak = append(weak, cipher)
+			}
+		}
+
+		if len(weak) > 0 {
+			findings = append(findings, n.Finding(SeverityError, fmt.Sprintf(
+				"ssl_ciphers enables weak ciphers: %s", strings.Join(weak, ", "),
+			)))
+		}
+	})
+
+	return findings
+}
+
+func isWeakCipher(cipher string) bool {
+	upper := strings.ToUpper(cipher)
+	if upper == "ALL" || upper == "ANULL" || upper == "ENULL" {
+		return true
+	}
+
+	for _, part := range strings.FieldsFunc(upper, func(r rune) bool { return r == '-' || r == '+' || r == '_' }) {
+		for _, weak := range weakCiphers {
+			if part == weak {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sslPreferServerCiphers reports TLS servers letting the client pick the
+// cipher suite.
+type sslPreferServerCiphers struct{}
+
+func (sslPreferServerCiphers) ID() string { return "ssl-prefer-server-ciphers" }
+
+func (sslPreferServerCiphers) Description() string {
+	return "ssl_prefer_server_ciphers on makes the server choose the cipher suite"
+}
+
+func (sslPreferServerCiphers) Check(tree *Tree) []Finding {
+	findings := []Finding{}
+
+	tree.Walk(func(n *Node) {
+		if n.Name() != "http" {
+			return
+		}
+
+		for _, server := range servers(n) {
+			if !isTLS(server) {
+				continue
+			}
+
+			prefer := inherited(server, "ssl_prefer_server_ciphers")
+			if prefer == nil || len(prefer.Args) == 0 || prefer.Args[0] != "on" {
+				findings = append(findings, server.Finding(SeverityWarning, fmt.Sprintf(
+					"ssl_prefer_server_ciphers on is missing in %s", describe(server),
+				)))
+			}
+		}
+	})
+
+	return findings
+}
+
+// missingSecurityHeaders reports servers and locations whose responses
+// have no HSTS or X-Content-Type-Options header. A location defining its
+// own add_header drops the ones of its server, so it is checked alone.
+type missingSecurityHeaders struct{}
+
+func (missingSecurityHeaders) ID() string { return "missing-security-headers" }
+
+func (missingSecurityHeaders) Description() string {
+	return "responses should have Strict-Transport-Security over TLS and X-Content-Type-Options"
+}
+
+func (missingSecurityHeaders) Check(tree *Tree) []Finding {
+	findings := []Finding{}
+
+	tree.Walk(func(n *Node) {
+		if n.Name() != "http" {
+			return
+		}
+
+		for _, server := range servers(n) {
+			tls := isTLS(server)
+
+			check := func(block *Node) {
+				headers := inheritedAll(block, "add_header")
+				missing := []string{}
+				if tls && !hasHeader(headers, "Strict-Transport-Security") {
+					missing = append(missing, "Strict-Transport-Security")
+				}
+				if !hasHeader(headers, "X-Content-Type-Options") {
+					missing = append(missing, "X-Content-Type-Options")
+				}
+
+				if len(missing) > 0 {
+					findings = append(findings, block.Finding(SeverityWarning, fmt.Sprintf(
+						"%s has no %s header", describe(block), strings.Join(missing, " or "),
+					)))
+				}
+			}
+
+			check(server)
+			server.walk(func(location *Node) {
+				if location.Name() == "location" && len(location.Find("add_header")) > 0 {
+					check(location)
+				}
+			})
+		}
+	})
+
+	return findings
+}
+
+func hasHeader(headers []*Node, name string) bool {
+	for _, header := range headers {
+		if len(header.Args) > 0 && strings.EqualFold(header.Args[0], name) {
+			return true
+		}
+	}
+	return false
+}
+
+// autoindexOn reports directory listings.
+type autoindexOn struct{}
+
+func (autoindexOn) ID() string { return "autoindex-on" }
+
+func (autoindexOn) Description() string {
+	return "autoindex on lists the files of directories"
+}
+
+func (autoindexOn) Check(tree *Tree) []Finding {
+	findings := []Finding{}
+
+	tree.Walk(func(n *Node) {
+		if n.Name() == "autoindex" && len(n.Args) > 0 && n.Args[0] == "on" {
+			findings = append(findings, n.Finding(SeverityWarning, "autoindex on lists the files of directories"))
+		}
+	})
+
+	return findings
+}
+
+// proxyPassSSRF reports proxy_pass targets whose host comes from the
+// request, letting clients make the server send requests anywhere.
+type proxyPassSSRF struct{}
+
+// variables the client controls
+var requestVariables = []string{"host", "http_host", "request_uri", "uri", "args", "query_string", "document_uri"}
+var requestVariablePrefixes = []string{"http_", "arg_", "cookie_"}
+
+var uriVariables = map[string]bool{"uri": true, "request_uri": true, "document_uri": true}
+
+func (proxyPassSSRF) ID() string { return "proxy-pass-ssrf" }
+
+func (proxyPassSSRF) Description() string {
+	return "the host of proxy_pass must not come from the request"
+}
+
+func (proxyPassSSRF) Check(tree *Tree) []Finding {
+	findings := []Finding{}
+	tainted := taintedVariables(tree)
+
+	passes := map[string]bool{"proxy_pass": true, "grpc_pass": true, "fastcgi_pass": true, "uwsgi_pass": true}
+
+	tree.Walk(func(n *Node) {
+		if !passes[n.Name()] || len(n.Args) == 0 {
+			return
+		}
+
+		host := n.Args[0]
+		if idx := strings.Index(host, "://"); idx >= 0 {
+			host = host[idx+3:]
+		}
+		if idx := strings.Index(host, "/"); idx >= 0 {
+			host = host[:idx]
+		}
+
+		for _, name := range variables(host) {
+			// a uri always starts with a slash, so it ends the host
+			if uriVariables[name] {
+				break
+			}
+			if tainted[name] {
+				findings = append(findings, n.Finding(SeverityError, fmt.Sprintf(
+					"the host of %s comes from the request through $%s, clients can make the server send requests anywhere",
+					n.Name(), name,
+				)))
+				return
+			}
+		}
+	})
+
+	return findings
+}
+
+// taintedVariables returns the variables derived from the request, through
+// set directives or map blocks using them.
+func taintedVariables(tree *Tree) map[string]bool {
+	tainted := map[string]bool{}
+	for _, name := range requestVariables {
+		tainted[name] = true
+	}
+	for _, prefix := range requestVariablePrefixes {
+		tainted[prefix] = true
+	}
+
+	// regex captures come from the uri or the host most of the time
+	isTainted := func(value string) bool {
+		for _, name := range variables(value) {
+			if tainted[name] || isNumber(name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// a variable can be derived from one defined later in the file
+	for changed := true; changed; {
+		changed = false
+		tree.Walk(func(n *Node) {
+			target, derived := "", false
+
+			switch n.Name() {
+			case "set":
+				if len(n.Args) == 2 && strings.HasPrefix(n.Args[0], "$") {
+					target, derived = n.Args[0][1:], isTainted(n.Args[1])
+				}
+			case "map":
+				if len(n.Args) == 2 && strings.HasPrefix(n.Args[1], "$") {
+					target = n.Args[1][1:]
+					source := isTainted(n.Args[0])
+					for _, entry := range n.Children {
+						// the captures of a regex key come from the source
+						for _, value := range entry.Args {
+							derived = derived || isTainted(value) || (source && len(variables(value)) > 0)
+						}
+					}
+				}
+			}
+
+			if derived && !tainted[target] {
+				tainted[target] = true
+				changed = true
+			}
+		})
+	}
+
+	return tainted
+}
+
+// variables returns the names of the variables used in value, the ones with
+// a request prefix like $http_x are returned as the prefix.
+func variables(value string) []string {
+	names := []string{}
+
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' {
+			continue
+		}
+
+		rest := value[i+1:]
+		end := 0
+		if strings.HasPrefix(rest, "{") {
+			if close := strings.Index(rest, "}"); close > 0 {
+				names = append(names, prefixed(rest[1:close]))
+				i += close + 1
+			}
+			continue
+		}
+
+		for end < len(rest) && (rest[end] == '_' || (rest[end] >= 'a' && rest[end] <= 'z') || (rest[end] >= 'A' && rest[end] <= 'Z') || (rest[end] >= '0' && rest[end] <= '9')) {
+			end++
+		}
+		if end > 0 {
+			names = append(names, prefixed(rest[:end]))
+			i += end
+		}
+	}
+
+	return names
+}
+
+func prefixed(name string) string {
+	for _, prefix := range requestVariablePrefixes {
+		if strings.HasPrefix(name, prefix) && name != "http_host" {
+			return prefix
+		}
+	}
+	return name
+}
+
+// allowAllBeforeDeny reports deny rules that are never reached, since access
+// rules are checked in order and the first match wins.
+type allowAllBeforeDeny struct{}
+
+func (allowAllBeforeDeny) ID() string { return "allow-all-before-deny" }
+
+func (allowAllBeforeDeny) Description() string {
+	return "deny rules after allow all are never reached"
+}
+
+func (allowAllBeforeDeny) Check(tree *Tree) []Finding {
+	findings := []Finding{}
+
+	check := func(n *Node) {
+		var allowAll *Node
+		for _, child := range n.Children {
+			switch {
+			case child.Name() == "allow" && len(child.Args) == 1 && child.Args[0] == "all":
+				if allowAll == nil {
+					allowAll = child
+				}
+			case child.Name() == "deny" && allowAll != nil:
+				findings = append(findings, child.Finding(SeverityError, fmt.Sprintf(
+					"deny %s is never reached, allow all at line %d matches first",
+					strings.Join(child.Args, " "), allowAll.Line,
+				)))
+				return
+			}
+		}
+	}
+
+	check(tree.Root)
+	tree.Walk(check)
+
+	return findings
+}
+
+// serverTokensOn reports server_tokens turned on explicitly.
+type serverTokensOn struct{}
+
+func (serverTokensOn) ID() string { return "server-tokens-on" }
+
+func (serverTokensOn) Description() string {
+	return "server_tokens on sends the nginx version in responses and error pages"
+}
+
+func (serverTokensOn) Check(tree *Tree) []Finding {
+	findings := []Finding{}
+
+	tree.Walk(func(n *Node) {
+		if n.Name() == "server_tokens" && len(n.Args) > 0 && n.Args[0] != "off" {
+			findings = append(findings, n.Finding(SeverityWarning, fmt.Sprintf(
+				"server_tokens %s sends the nginx version in responses", n.Args[0],
+			)))
+		}
+	})
+
+	return findings
+}
+
+// isTLS reports whether a server block accepts TLS connections.
+func isTLS(server *Node) bool {
+	for _, listen := range server.Find("listen") {
+		for i, arg := range listen.Args {
+			if i > 0 && (arg == "ssl" || arg == "quic") {
+				return true
+			}
+		}
+	}
+	return server.Has("ssl", "on")
+}
+
+// inherited returns the last directive with the name in the block or the
+// closest parent defining it.
+func inherited(n *Node, name string) *Node {
+	all := inheritedAll(n, name)
+	if len(all) == 0 {
+		return nil
+	}
+	return all[len(all)-1]
+}
+
+// inheritedAll returns the directives with the name of the block, or of the
+// closest parent defining any, like nginx inherits arrays.
+func inheritedAll(n *Node, name string) []*Node {
+	for block := n; block != nil; block = block.Parent {
+		if found := block.Find(name); len(found) > 0 {
+			return found
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package lint
+
+import (
+	"strings"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+)
+
+// Tree is the config with its includes followed, every directive knowing
+// the file it was parsed from and its parent block.
+type Tree struct {
+	Root *Node
+}
+
+type Node struct {
+	crossplane.Directive
+	File     string
+	Parent   *Node
+	Children []*Node
+}
+
+func NewTree(conf *crossplane.Payload) (*Tree, error) {
+	inlined, err := crossplane.Inline(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &Node{File: inlined.File(&inlined.Parsed)}
+	addChildren(inlined, root, &inlined.Parsed)
+	return &Tree{Root: root}, nil
+}
+
+func addChildren(inlined *crossplane.Inlined, parent *Node, block *[]crossplane.Directive) {
+	for i, d := range *block {
+		if d.IsComment() {
+			continue
+		}
+
+		node := &Node{Directive: d, File: inlined.DirectiveFile(block, i), Parent: parent}
+		parent.Children = append(parent.Children, node)
+
+		if d.Block != nil {
+			addChildren(inlined, node, d.Block)
+		}
+	}
+}
+
+// Walk calls fn for every directive of the tree, parents first.
+func (t *Tree) Walk(fn func(n *Node)) {
+	t.Root.walk(fn)
+}
+
+func (n *Node) walk(fn func(n *Node)) {
+	for _, child := range n.Children {
+		fn(child)
+		child.walk(fn)
+	}
+}
+
+// Find returns the children of a block with the given directive name.
+func (n *Node) Find(name string) []*Node {
+	found := []*Node{}
+	for _, child := range n.Children {
+		if child.Directive.Directive == name {
+			found = append(found, child)
+		}
+	}
+	return found
+}
+
+// Has reports whether the block has a child with the given name and args.
+func (n *Node) Has(name string, args ...string) bool {
+	for _, child := range n.Find(name) {
+		if len(args) == 0 || strings.Join(child.Args, " ") == strings.Join(args, " ") {
+			return true
+		}
+	}
+	return false
+}
+
+// Name returns the directive name.
+func (n *Node) Name() string {
+	return n.Directive.Directive
+}
+
+// Inside returns the closest parent block with the given name, or nil.
+func (n *Node) Inside(name string) *Node {
+	for parent := n.Parent; parent != nil; parent = parent.Parent {
+		if parent.Name() == name {
+			return parent
+		}
+	}
+	return nil
+}
+
+// Finding returns a finding located at the directive.
+func (n *Node) Finding(severity Severity, message string) Finding {
+	return Finding{
+		Severity: severity,
+		File:     n.File,
+		Line:     n.Line,
+		Message:  message,
+	}
+}
@@ -0,0 +1,178 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+)
+
+// completion completes the directives allowed in the block the offset is
+// in, and the value of flag directives.
+func (s *Server) completion(doc *document, offset int) []CompletionItem {
+	items := []CompletionItem{}
+
+	ctx, known := s.context(doc)
+	cursor := crossplane.CursorAt(doc.text, offset, ctx)
+	// a file that isn't included from the main config is likely included
+	// from the http block
+	if !known && len(cursor.Context) > 0 && len(crossplane.DirectivesAllowed(cursor.Context)) == 0 {
+		cursor = crossplane.CursorAt(doc.text, offset, []string{"http"})
+	}
+	if strings.HasPrefix(cursor.Word, "#") {
+		return items
+	}
+
+	if len(cursor.Statement) == 1 {
+		info, ok := crossplane.LookupDirective(cursor.Statement[0])
+		if ok && info.Flag {
+			for _, value := range []string{"on", "off"} {
+				if strings.HasPrefix(value, cursor.Word) {
+					items = append(items, CompletionItem{Label: value, Kind: completionKindKeyword})
+				}
+			}
+		}
+		return items
+	}
+
+	if len(cursor.Statement) > 0 {
+		return items
+	}
+
+	names := crossplane.DirectivesAllowed(cursor.Context)
+	// the top level of a file of an unknown context can be any block
+	if !known && len(cursor.Context) == 0 {
+		names = crossplane.Directives()
+	}
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, cursor.Word) {
+			continue
+		}
+		info, _ := crossplane.LookupDirective(name)
+		items = append(items, CompletionItem{
+			Label:  name,
+			Kind:   completionKindKeyword,
+			Detail: "arguments: " + strings.Join(info.Args, ", "),
+		})
+	}
+
+	return items
+}
+
+// context returns the block context the document is included from, false
+// if the main config doesn't include it.
+func (s *Server) context(doc *document) ([]string, bool) {
+	if s.config == "" {
+		return nil, false
+	}
+	if samePath(s.config, doc.path) {
+		return []string{}, true
+	}
+
+	payload, config := s.parse(doc)
+	if config == nil || len(payload.Config) == 0 || config == &payload.Config[0] {
+		return nil, false
+	}
+
+	for i := range payload.Config {
+		if &payload.Config[i] == config {
+			return includeContext(payload, payload.Config[0].Parsed, []string{}, i, map[int]bool{0: true})
+		}
+	}
+	return nil, false
+}
+
+// includeContext returns the context of the first include of the config
+// with the given index, following the includes of the block.
+func includeContext(payload *crossplane.Payload, block []crossplane.Directive, ctx []string, idx int, including map[int]bool) ([]string, bool) {
+	for _, d := range block {
+		if d.IsInclude() {
+			for _, included := range *d.Includes {
+				if included == idx {
+					return ctx, true
+				}
+				if included < 0 || included >= len(payload.Config) || including[included] {
+					continue
+				}
+
+				including[included] = true
+				found, ok := includeContext(payload, payload.Config[included].Parsed, ctx, idx, including)
+				delete(including, included)
+				if ok {
+					return found, true
+				}
+			}
+		}
+
+		if d.Block != nil {
+			inner := append(append([]string{}, ctx...), d.Directive)
+			// locations don't nest, like in analyze
+			if len(ctx) > 0 && ctx[0] == "http" && d.Directive == "location" {
+				inner = []string{"http", "location"}
+			}
+			if found, ok := includeContext(payload, *d.Block, inner, idx, including); ok {
+				return found, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// hover describes the directive whose name the offset is on.
+func (s *Server) hover(doc *document, offset int) *Hover {
+	_, config := s.parse(doc)
+	if config == nil {
+		return nil
+	}
+
+	d, arg := directiveAt(config.Parsed, offset)
+	if d == nil || arg >= 0 {
+		return nil
+	}
+
+	info, ok := crossplane.LookupDirective(d.Directive)
+	if !ok {
+		return nil
+	}
+
+	contexts := make([]string, 0, len(info.Contexts))
+	for _, ctx := range info.Contexts {
+		contexts = append(contexts, "`"+strings.Join(ctx, " > ")+"`")
+	}
+
+	value := fmt.Sprintf("**%s**", info.Name)
+	if info.Block {
+		value += " { ... }"
+	}
+	value += fmt.Sprintf("\n\nArguments: %s\n\nContexts: %s\n\n[nginx documentation](https://nginx.org/r/%s)",
+		strings.Join(info.Args, ", "), strings.Join(contexts, ", "), info.Name)
+
+	rng := rangeOf(doc.text, *d)
+	return &Hover{
+		Contents: markupContent{Kind: "markdown", Value: value},
+		Range:    &rng,
+	}
+}
+
+// directiveAt returns the directive of a block whose name or argument is at
+// the offset, with the index of the argument or -1 for the name.
+func directiveAt(block []crossplane.Directive, offset int) (*crossplane.Directive, int) {
+	for i := range block {
+		d := &block[i]
+		if d.Range != nil && d.Range.Contains(offset) {
+			return d, -1
+		}
+		for j, rng := range d.ArgRanges {
+			if rng.Contains(offset) {
+				return d, j
+			}
+		}
+		if d.Block != nil {
+			if inner, arg := directiveAt(*d.Block, offset); inner != nil {
+				return inner, arg
+			}
+		}
+	}
+	return nil, -1
+}
@@ -0,0 +1,95 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/adityals/go-ngx-config/internal/lint"
+)
+
+// directives passing requests to a server or an upstream block
+var passDirectives = map[string]bool{
+	"proxy_pass":     true,
+	"grpc_pass":      true,
+	"fastcgi_pass":   true,
+	"uwsgi_pass":     true,
+	"scgi_pass":      true,
+	"memcached_pass": true,
+}
+
+// definition returns the files of an include, the upstream block of a pass
+// directive, or the named location of an @name argument at the offset.
+func (s *Server) definition(doc *document, offset int) []Location {
+	locations := []Location{}
+
+	payload, config := s.parse(doc)
+	if config == nil {
+		return locations
+	}
+
+	d, arg := directiveAt(config.Parsed, offset)
+	if d == nil || arg < 0 {
+		return locations
+	}
+	value := d.Args[arg]
+
+	if d.IsInclude() {
+		for _, idx := range *d.Includes {
+			if idx >= 0 && idx < len(payload.Config) {
+				locations = append(locations, Location{URI: pathToUri(payload.Config[idx].File)})
+			}
+		}
+		return locations
+	}
+
+	tree, err := lint.NewTree(payload)
+	if err != nil {
+		return locations
+	}
+	var found []*lint.Node
+	switch {
+	case strings.HasPrefix(value, "@"):
+		found = findBlocks(tree, "location", value)
+	case passDirectives[d.Directive] && arg == 0:
+		if name := upstreamName(value); name != "" {
+			found = findBlocks(tree, "upstream", name)
+		}
+	}
+
+	texts := map[string]string{}
+	for _, n := range found {
+		if _, ok := texts[n.File]; !ok {
+			texts[n.File] = s.text(n.File)
+		}
+		locations = append(locations, Location{
+			URI:   pathToUri(n.File),
+			Range: rangeOf(texts[n.File], n.Directive),
+		})
+	}
+	return locations
+}
+
+// findBlocks returns the blocks with the given name and single argument.
+func findBlocks(tree *lint.Tree, name string, arg string) []*lint.Node {
+	found := []*lint.Node{}
+	tree.Walk(func(n *lint.Node) {
+		if n.Name() == name && len(n.Args) == 1 && n.Args[0] == arg {
+			found = append(found, n)
+		}
+	})
+	return found
+}
+
+// upstreamName returns the name of the upstream block a pass address may
+// refer to, an address with a port or a variable refers to none.
+func upstreamName(pass string) string {
+	if idx := strings.Index(pass, "://"); idx >= 0 {
+		pass = pass[idx+3:]
+	}
+	if idx := strings.Index(pass, "/"); idx >= 0 {
+		pass = pass[:idx]
+	}
+	if pass == "" || strings.ContainsAny(pass, ":$") {
+		return ""
+	}
+	return pass
+}
@@ -0,0 +1,53 @@
+package lsp
+
+import (
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+)
+
+// diagnostics returns the errors found by the parser in the document.
+func (s *Server) diagnostics(doc *document) []Diagnostic {
+	diagnostics := []Diagnostic{}
+
+	payload, _ := s.parse(doc)
+	if payload == nil {
+		return diagnostics
+	}
+
+	for _, perr := range payload.Errors {
+		if !samePath(perr.File, doc.path) {
+			continue
+		}
+
+		diagnostic := Diagnostic{
+			Severity: severityError,
+			Code:     perr.Kind,
+			Source:   "go-ngx-config",
+			Message:  perr.Message(),
+		}
+		switch {
+		case perr.Range != nil:
+			diagnostic.Range = toRange(doc.text, *perr.Range)
+		case perr.Line != nil:
+			diagnostic.Range = lineRange(doc.text, *perr.Line)
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+
+	return diagnostics
+}
+
+func (s *Server) publishDiagnostics(doc *document) *rpcError {
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         doc.uri,
+		Diagnostics: s.diagnostics(doc),
+	})
+}
+
+// rangeOf returns the range of a parsed directive of the document, the
+// range of its line if it was not parsed from a text.
+func rangeOf(text string, d crossplane.Directive) Range {
+	if d.Range != nil {
+		return toRange(text, *d.Range)
+	}
+	return lineRange(text, d.Line)
+}
@@ -0,0 +1,148 @@
+package lsp
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+)
+
+// document is a config opened in the editor, its text may not be saved.
+type document struct {
+	uri  string
+	path string
+	text string
+}
+
+// offset returns the byte offset of a position, whose character counts
+// UTF-16 code units.
+func (d *document) offset(pos Position) int {
+	return toOffset(d.text, pos)
+}
+
+// parse parses the main config if it includes the document, the document
+// alone otherwise, with the opened documents read instead of their files.
+// It returns the parsed config of the document too, nil if it has none.
+func (s *Server) parse(doc *document) (*crossplane.Payload, *crossplane.Config) {
+	options := &crossplane.ParseOptions{
+		Open: s.open,
+	}
+
+	if s.config != "" && !samePath(s.config, doc.path) {
+		payload, err := crossplane.Parse(s.config, options)
+		if err == nil {
+			for i := range payload.Config {
+				if samePath(payload.Config[i].File, doc.path) {
+					return payload, &payload.Config[i]
+				}
+			}
+		}
+	}
+
+	// a file included from a block can't be checked against its context
+	if s.config == "" || !samePath(s.config, doc.path) {
+		options.SkipDirectiveContextCheck = true
+	}
+	payload, err := crossplane.Parse(doc.path, options)
+	if err != nil || len(payload.Config) == 0 {
+		return payload, nil
+	}
+	return payload, &payload.Config[0]
+}
+
+// open reads an opened document from the editor, a file from the disk.
+func (s *Server) open(path string) (io.Reader, error) {
+	for _, doc := range s.documents {
+		if samePath(doc.path, path) {
+			return strings.NewReader(doc.text), nil
+		}
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(content), nil
+}
+
+// text returns the text of a file, from the editor if it is opened.
+func (s *Server) text(path string) string {
+	r, err := s.open(path)
+	if err != nil {
+		return ""
+	}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+func samePath(a string, b string) bool {
+	if abs, err := filepath.Abs(a); err == nil {
+		a = abs
+	}
+	if abs, err := filepath.Abs(b); err == nil {
+		b = abs
+	}
+	return a == b
+}
+
+// toOffset returns the byte offset of a position in a text.
+func toOffset(text string, pos Position) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		idx := strings.IndexByte(text[offset:], '\n')
+		if idx < 0 {
+			return len(text)
+		}
+		offset += idx + 1
+	}
+
+	for units := 0; units < pos.Character && offset < len(text) && text[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		units += utf16.RuneLen(r)
+		offset += size
+	}
+	return offset
+}
+
+// toPosition returns the position of a parsed position, the text of its file
+// is used to count UTF-16 code units when it is known.
+func toPosition(text string, pos crossplane.Position) Position {
+	if text == "" || pos.Offset > len(text) {
+		return Position{Line: pos.Line - 1, Character: pos.Column - 1}
+	}
+
+	start := strings.LastIndexByte(text[:pos.Offset], '\n') + 1
+	character := 0
+	for _, r := range text[start:pos.Offset] {
+		character += utf16.RuneLen(r)
+	}
+	return Position{Line: strings.Count(text[:pos.Offset], "\n"), Character: character}
+}
+
+func toRange(text string, rng crossplane.Range) Range {
+	return Range{Start: toPosition(text, rng.Start), End: toPosition(text, rng.End)}
+}
+
+// lineRange returns the range of a whole line, starting at 1, for the
+// errors that only know their line.
+func lineRange(text string, line int) Range {
+	lines := strings.Split(text, "\n")
+	end := 0
+	if line >= 1 && line <= len(lines) {
+		for _, r := range strings.TrimRight(lines[line-1], "\r") {
+			end += utf16.RuneLen(r)
+		}
+	}
+	return Range{
+		Start: Position{Line: line - 1},
+		End:   Position{Line: line - 1, Character: end},
+	}
+}
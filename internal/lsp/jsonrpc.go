@@ -0,0 +1,62 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// readMessage reads the content of a message framed by its Content-Length
+// header.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			return nil, fmt.Errorf("invalid header %q", line)
+		}
+		if strings.EqualFold(strings.TrimSpace(line[:idx]), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[idx+1:]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid content length %q", line)
+			}
+		}
+	}
+
+	if length < 0 {
+		return nil, fmt.Errorf("missing content length")
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// writeMessage writes a message with its Content-Length header.
+func writeMessage(w io.Writer, v interface{}) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(content)); err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
@@ -0,0 +1,234 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Options of the language server.
+type Options struct {
+	// Main config the opened files are included from, e.g.
+	// /etc/nginx/nginx.conf. It defaults to the initialization option
+	// "config", then to nginx.conf in the workspace root.
+	Config string
+}
+
+// Server is a language server for nginx configs speaking LSP over a reader
+// and a writer, e.g. stdin and stdout.
+type Server struct {
+	in        *bufio.Reader
+	out       io.Writer
+	config    string
+	documents map[string]*document
+	shutdown  bool
+}
+
+type rpcError struct {
+	code    int
+	message string
+}
+
+func NewServer(in io.Reader, out io.Writer, options *Options) *Server {
+	s := &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: map[string]*document{},
+	}
+	if options != nil {
+		s.config = options.Config
+	}
+	return s
+}
+
+// Serve handles the messages of the client until it exits.
+func (s *Server) Serve() error {
+	for {
+		content, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(content, &req); err != nil {
+			if err := s.reply(nil, nil, &rpcError{codeParseError, err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+
+		result, rerr := s.handle(req)
+
+		// notifications have no response
+		if req.ID == nil {
+			if rerr != nil {
+				logrus.Warnf("[LSP] %s: %s", req.Method, rerr.message)
+			}
+			continue
+		}
+		if err := s.reply(req.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(req request) (interface{}, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		var params initializeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{codeInvalidParams, err.Error()}
+		}
+		return s.initialize(params), nil
+
+	case "initialized", "$/cancelRequest", "$/setTrace", "textDocument/didSave":
+		return nil, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{codeInvalidParams, err.Error()}
+		}
+		doc := &document{uri: params.TextDocument.URI, path: uriToPath(params.TextDocument.URI), text: params.TextDocument.Text}
+		s.documents[doc.uri] = doc
+		return nil, s.publishDiagnostics(doc)
+
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{codeInvalidParams, err.Error()}
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok || len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// the whole text is synced on every change
+		doc.text = params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, s.publishDiagnostics(doc)
+
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{codeInvalidParams, err.Error()}
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+
+	case "textDocument/completion", "textDocument/hover", "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{codeInvalidParams, err.Error()}
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+
+		offset := doc.offset(params.Position)
+		switch req.Method {
+		case "textDocument/completion":
+			return s.completion(doc, offset), nil
+		case "textDocument/hover":
+			return s.hover(doc, offset), nil
+		default:
+			return s.definition(doc, offset), nil
+		}
+
+	case "textDocument/documentSymbol":
+		var params documentSymbolParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{codeInvalidParams, err.Error()}
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return []DocumentSymbol{}, nil
+		}
+		return s.symbols(doc), nil
+	}
+
+	return nil, &rpcError{codeMethodNotFound, "method not found: " + req.Method}
+}
+
+func (s *Server) initialize(params initializeParams) interface{} {
+	if s.config == "" {
+		s.config = params.InitializationOptions.Config
+	}
+	if s.config == "" && params.RootURI != "" {
+		config := filepath.Join(uriToPath(params.RootURI), "nginx.conf")
+		if _, err := os.Stat(config); err == nil {
+			s.config = config
+		}
+	}
+	if s.config != "" {
+		logrus.Info("[LSP] Config: ", s.config)
+	}
+
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":       textDocumentSyncFull,
+			"completionProvider":     map[string]interface{}{},
+			"hoverProvider":          true,
+			"definitionProvider":     true,
+			"documentSymbolProvider": true,
+		},
+		"serverInfo": map[string]interface{}{
+			"name": "go-ngx-config",
+		},
+	}
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, rerr *rpcError) error {
+	if rerr != nil {
+		return writeMessage(s.out, errorResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error:   responseError{Code: rerr.code, Message: rerr.message},
+		})
+	}
+	return writeMessage(s.out, response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *Server) notify(method string, params interface{}) *rpcError {
+	if err := writeMessage(s.out, notification{JSONRPC: "2.0", Method: method, Params: params}); err != nil {
+		return &rpcError{codeParseError, err.Error()}
+	}
+	return nil
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToUri(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
@@ -0,0 +1,83 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+)
+
+// symbols returns the blocks of the document, servers named by their
+// server_name.
+func (s *Server) symbols(doc *document) []DocumentSymbol {
+	_, config := s.parse(doc)
+	if config == nil {
+		return []DocumentSymbol{}
+	}
+	return blockSymbols(doc.text, config.Parsed)
+}
+
+func blockSymbols(text string, block []crossplane.Directive) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+
+	for _, d := range block {
+		if d.Block == nil || d.Range == nil {
+			continue
+		}
+
+		symbol := DocumentSymbol{
+			Name:           d.Directive,
+			Detail:         strings.Join(d.Args, " "),
+			Kind:           symbolKind(d.Directive),
+			SelectionRange: toRange(text, *d.Range),
+			Children:       blockSymbols(text, *d.Block),
+		}
+		if d.Directive == "server" {
+			for _, inner := range *d.Block {
+				if inner.Directive == "server_name" || (inner.Directive == "listen" && symbol.Detail == "") {
+					symbol.Detail = strings.Join(inner.Args, " ")
+				}
+			}
+		}
+
+		// the closing brace isn't kept, so the block ends with its last
+		// directive
+		symbol.Range = Range{Start: symbol.SelectionRange.Start, End: toPosition(text, end(d))}
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols
+}
+
+// end returns the end of the last token of a directive and its block.
+func end(d crossplane.Directive) crossplane.Position {
+	last := d.Range.End
+	if len(d.ArgRanges) > 0 {
+		last = d.ArgRanges[len(d.ArgRanges)-1].End
+	}
+
+	if d.Block != nil {
+		for _, inner := range *d.Block {
+			if inner.Range == nil {
+				continue
+			}
+			if pos := end(inner); pos.Offset > last.Offset {
+				last = pos
+			}
+		}
+	}
+	return last
+}
+
+func symbolKind(name string) int {
+	switch name {
+	case "server":
+		return symbolKindClass
+	case "location":
+		return symbolKindMethod
+	case "upstream":
+		return symbolKindStruct
+	case "http", "stream", "mail", "events":
+		return symbolKindModule
+	}
+	return symbolKindNamespace
+}
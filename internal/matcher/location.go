@@ -3,22 +3,36 @@ package matcher
 import (
 	"errors"
 	"net/url"
-	"regexp"
 	"strings"
 
 	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/internal/pcre"
 )
 
 type LocationMatcher struct {
 	MatchPath    string
 	MatchModifer string
 	Directives   crossplane.Directive
+	Server       *ServerMatcher
+	Chain        []LocationMatcher
+	Hops         []LocationHop
+	Uri          string
+	Status       int
+
+	// Captures of the last regex location matched, starting with the whole
+	// match like $0, and its named captures.
+	Captures      []string
+	NamedCaptures map[string]string
+
+	RegexErrors []RegexError
 }
 
 type locationDirective struct {
-	Modifier   string
-	Path       string
-	Directives crossplane.Directive
+	Modifier      string
+	Path          string
+	Directives    crossplane.Directive
+	Captures      []string
+	NamedCaptures map[string]string
 }
 
 const (
@@ -43,8 +57,16 @@ func getLocation(directive []crossplane.Directive, locationDirectives *[]crosspl
 }
 
 func NewLocationMatcher(conf *crossplane.Payload, targetPath string) (*LocationMatcher, error) {
+	return NewLocationMatcherWithOptions(conf, targetPath, nil)
+}
+
+func NewLocationMatcherWithOptions(conf *crossplane.Payload, targetPath string, opts *MatchOptions) (*LocationMatcher, error) {
 	if conf == nil {
-		return nil, errors.New("no config can be compute")
+		return nil, crossplane.ErrNoConfig
+	}
+
+	if opts == nil {
+		opts = &MatchOptions{}
 	}
 
 	parsedUrl, err := url.Parse(targetPath)
@@ -52,21 +74,146 @@ func NewLocationMatcher(conf *crossplane.Payload, targetPath string) (*LocationM
 		return nil, err
 	}
 
-	locations := make([]locationDirective, 0)
+	scope, err := NewScope(conf, parsedUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return scope.Follow(parsedUrl, opts)
+}
+
+// Scope is what the locations of a request are matched against once its
+// server block is picked, so they can be matched again when the uri changes.
+type Scope struct {
+	http      *crossplane.Directive
+	server    *ServerMatcher
+	locations []locationDirective
+	named     map[string]locationDirective
+	sources   *sources
+}
+
+func NewScope(conf *crossplane.Payload, parsedUrl *url.URL) (*Scope, error) {
+	// servers need their included files to know all of their locations
+	parsed, src, err := inlineConfigs(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	serverDirectives := make([]crossplane.Directive, 0)
+	getServers(parsed, &serverDirectives)
+
+	server, err := matchServer(serverDirectives, parsedUrl, src)
+	if err != nil {
+		return nil, err
+	}
+
 	locationDirectives := make([]crossplane.Directive, 0)
 
-	for _, v := range conf.Config {
-		getLocation(v.Parsed, &locationDirectives)
+	// without any server block, e.g. a single included file, every
+	// location is a candidate
+	if server != nil {
+		getLocation(*server.Directives.Block, &locationDirectives)
+	} else {
+		getLocation(parsed, &locationDirectives)
 	}
 
 	if len(locationDirectives) == 0 {
 		return nil, errors.New("no location(s) found")
 	}
 
+	scope := &Scope{
+		server:    server,
+		locations: newLocationDirectives(locationDirectives),
+		named:     map[string]locationDirective{},
+		sources:   src,
+	}
+
+	for i, d := range parsed {
+		if d.Directive == "http" && d.Block != nil {
+			scope.http = &parsed[i]
+		}
+	}
+
+	for _, directive := range locationDirectives {
+		if len(directive.Args) == 1 && strings.HasPrefix(directive.Args[0], "@") {
+			scope.named[directive.Args[0]] = locationDirective{
+				Directives: directive,
+				Modifier:   "",
+				Path:       directive.Args[0],
+			}
+		}
+	}
+
+	return scope, nil
+}
+
+// Server returns the server block handling the request, or nil if the
+// config has no server blocks.
+func (s *Scope) Server() *ServerMatcher {
+	return s.server
+}
+
+// Parents returns the server and http blocks around the locations, from the
+// innermost one.
+func (s *Scope) Parents() []crossplane.Directive {
+	return s.parents()
+}
+
+// File returns the file a block of the config was parsed from.
+func (s *Scope) File(block *[]crossplane.Directive) string {
+	return s.sources.file(block)
+}
+
+// DirectiveFile returns the file the directive at index idx of a block was
+// parsed from.
+func (s *Scope) DirectiveFile(block *[]crossplane.Directive, idx int) string {
+	return s.sources.entry(block, idx)
+}
+
+// Compile compiles a regex of a directive found in the block. If it can't be
+// evaluated, nil is returned and the error is kept in RegexErrors.
+func (s *Scope) Compile(block *[]crossplane.Directive, line int, expr string, caseless bool) *pcre.Regexp {
+	return s.sources.compile(block, line, expr, caseless)
+}
+
+// Find matches a regex compiled with Compile. If the subject can't be
+// evaluated, the error is kept in RegexErrors too.
+func (s *Scope) Find(block *[]crossplane.Directive, line int, reg *pcre.Regexp, subject string) ([]string, error) {
+	return s.sources.find(block, line, reg, subject)
+}
+
+// RegexErrors returns the regexes that couldn't be evaluated so far.
+func (s *Scope) RegexErrors() []RegexError {
+	return s.sources.regexErrors
+}
+
+// Match finds the location handling the uri path.
+func (s *Scope) Match(path string) (*LocationMatcher, error) {
+	match, err := locationTester(s.locations, path, s.sources)
+	if err != nil {
+		return nil, err
+	}
+
+	if match != nil {
+		match.Server = s.server
+	}
+
+	return match, nil
+}
+
+func newLocationDirectives(locationDirectives []crossplane.Directive) []locationDirective {
+	locations := make([]locationDirective, 0)
+
 	for _, directive := range locationDirectives {
 		args := directive.Args
 		if len(args) == 1 {
 			path := args[0]
+
+			// named locations are only used for internal redirects
+			if strings.HasPrefix(path, "@") {
+				continue
+			}
+
 			locations = append(locations, locationDirective{
 				Directives: directive,
 				Modifier:   "",
@@ -85,97 +232,142 @@ func NewLocationMatcher(conf *crossplane.Payload, targetPath string) (*LocationM
 
 	}
 
-	match, err := locationTester(locations, parsedUrl.Path)
+	return locations
+}
+
+// nestedLocations returns the locations defined inside a location block.
+func (l locationDirective) nestedLocations() []locationDirective {
+	if l.Directives.Block == nil {
+		return nil
+	}
+
+	locationDirectives := make([]crossplane.Directive, 0)
+	getLocation(*l.Directives.Block, &locationDirectives)
+	return newLocationDirectives(locationDirectives)
+}
+
+func (l locationDirective) toMatcher() LocationMatcher {
+	return LocationMatcher{
+		MatchPath:     l.Path,
+		MatchModifer:  l.Modifier,
+		Directives:    l.Directives,
+		Captures:      l.Captures,
+		NamedCaptures: l.NamedCaptures,
+	}
+}
+
+// locationTester finds the location handling targetPath, descending into
+// nested locations, and reports the chain of matched locations with the
+// innermost one as the match.
+func locationTester(locationsTarget []locationDirective, targetPath string, src *sources) (*LocationMatcher, error) {
+	chain, _, err := findLocation(locationsTarget, targetPath, src)
 	if err != nil {
 		return nil, err
 	}
 
-	if match == nil {
-		return nil, errors.New("no match found")
+	if len(chain) == 0 {
+		return nil, nil
+	}
+
+	match := chain[len(chain)-1].toMatcher()
+	for _, location := range chain {
+		match.Chain = append(match.Chain, location.toMatcher())
 
+		// captures are kept from the last regex that matched
+		if location.Captures != nil {
+			match.Captures = location.Captures
+			match.NamedCaptures = location.NamedCaptures
+		}
 	}
 
-	return &LocationMatcher{
-		MatchModifer: match.MatchModifer,
-		MatchPath:    match.MatchPath,
-		Directives:   match.Directives,
-	}, nil
+	return &match, nil
 }
 
-func locationTester(locationsTarget []locationDirective, targetPath string) (*LocationMatcher, error) {
+type findResult int
+
+const (
+	// nothing matched at this level
+	findDeclined findResult = iota
+	// a prefix matched, regex locations can still override it
+	findAgain
+	// a regex matched, this is final
+	findOk
+	// an exact location matched, this is final
+	findDone
+)
+
+// findLocation follows ngx_http_core_find_location: the longest prefix is
+// looked up first and its nested locations are searched before the regex
+// locations of the current level are tried in order.
+func findLocation(locationsTarget []locationDirective, targetPath string, src *sources) ([]locationDirective, findResult, error) {
+	chain := []locationDirective{}
+	result := findDeclined
+	noRegex := false
+
 	// handle exact
 	for _, location := range locationsTarget {
-		if location.Modifier != EXACT {
-			continue
-		}
-
-		if location.Path == targetPath {
-			return &LocationMatcher{
-				MatchPath:    location.Path,
-				MatchModifer: location.Modifier,
-				Directives:   location.Directives,
-			}, nil
+		if location.Modifier == EXACT && location.Path == targetPath {
+			return []locationDirective{location}, findDone, nil
 		}
 	}
 
 	// handle prefix and prefix priority
-	var bestMatch locationDirective
-	bestLength := 0
-	for _, location := range locationsTarget {
+	var bestMatch *locationDirective
+	for i, location := range locationsTarget {
 		if location.Modifier != PREFIX && location.Modifier != PREFIX_PRIORITY {
 			continue
 		}
 
 		if strings.HasPrefix(targetPath, location.Path) {
-			locationLength := len(location.Path)
-			if locationLength > bestLength {
-				bestMatch = location
-				bestLength = locationLength
+			if bestMatch == nil || len(location.Path) > len(bestMatch.Path) {
+				bestMatch = &locationsTarget[i]
 			}
 		}
 	}
 
-	// do not go to regex if priority
-	if bestMatch.Path != "" && bestMatch.Modifier == PREFIX_PRIORITY {
-		return &LocationMatcher{
-			MatchPath:    bestMatch.Path,
-			MatchModifer: bestMatch.Modifier,
-			Directives:   bestMatch.Directives,
-		}, nil
+	if bestMatch != nil {
+		// do not go to regex if priority
+		noRegex = bestMatch.Modifier == PREFIX_PRIORITY
+
+		nested, nestedResult, err := findLocation(bestMatch.nestedLocations(), targetPath, src)
+		if err != nil {
+			return nil, findDeclined, err
+		}
+
+		chain = append([]locationDirective{*bestMatch}, nested...)
+		result = findAgain
+		if nestedResult == findOk || nestedResult == findDone {
+			return chain, nestedResult, nil
+		}
+	}
+
+	if noRegex {
+		return chain, result, nil
 	}
 
 	// handle regex
 	for _, location := range locationsTarget {
-		if location.Modifier == REGEX || location.Modifier == REGEX_NO_CASE_SENSITIVE {
-			locationRegex := location.Path
-			if location.Modifier == REGEX_NO_CASE_SENSITIVE {
-				locationRegex = "(?i)" + locationRegex
-			}
+		if location.Modifier != REGEX && location.Modifier != REGEX_NO_CASE_SENSITIVE {
+			continue
+		}
 
-			reg, err := regexp.Compile(locationRegex)
+		// a regex that can't be evaluated is reported and skipped
+		reg := src.compile(location.Directives.Block, location.Directives.Line, location.Path, location.Modifier == REGEX_NO_CASE_SENSITIVE)
+		if reg == nil {
+			continue
+		}
+
+		if submatches, _ := src.find(location.Directives.Block, location.Directives.Line, reg, targetPath); submatches != nil {
+			location.Captures, location.NamedCaptures = pcre.Captures(reg, submatches)
+			nested, _, err := findLocation(location.nestedLocations(), targetPath, src)
 			if err != nil {
-				return nil, err
+				return nil, findDeclined, err
 			}
 
-			match := reg.FindString(targetPath)
-			if match != "" {
-				return &LocationMatcher{
-					MatchPath:    location.Path,
-					MatchModifer: location.Modifier,
-					Directives:   location.Directives,
-				}, nil
-			}
+			return append([]locationDirective{location}, nested...), findOk, nil
 		}
 	}
 
 	// use longest match
-	if bestMatch.Path != "" {
-		return &LocationMatcher{
-			MatchPath:    bestMatch.Path,
-			MatchModifer: bestMatch.Modifier,
-			Directives:   bestMatch.Directives,
-		}, nil
-	}
-
-	return nil, nil
+	return chain, result, nil
 }
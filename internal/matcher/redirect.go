@@ -0,0 +1,400 @@
+package matcher
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+)
+
+// nginx stops after this many internal redirects of a single request
+const maxUriChanges = 10
+
+// FileSystem tells the location tester which files exist when it evaluates
+// try_files.
+type FileSystem interface {
+	Stat(path string) (exists bool, isDir bool)
+}
+
+// FileList is a FileSystem made of file paths. A directory exists if a path
+// ends with a slash or if any file is inside of it.
+type FileList []string
+
+func (f FileList) Stat(name string) (bool, bool) {
+	name = path.Clean("/" + name)
+	for _, file := range f {
+		cleaned := path.Clean("/" + file)
+		if cleaned == name {
+			return true, strings.HasSuffix(file, "/")
+		}
+		if strings.HasPrefix(cleaned, strings.TrimSuffix(name, "/")+"/") {
+			return true, true
+		}
+	}
+	return false, false
+}
+
+type MatchOptions struct {
+	// Files that exist when evaluating try_files, no files exist if nil.
+	FileSystem FileSystem
+}
+
+// LocationHop is a location the request went through, either the first one
+// matched or one reached by an internal redirect.
+type LocationHop struct {
+	Directive    string
+	Uri          string
+	Status       int
+	MatchPath    string
+	MatchModifer string
+}
+
+// Redirects are the internal redirects of a request done by try_files and
+// error_page, with the locations it went through.
+type Redirects struct {
+	Hops []LocationHop
+
+	// Internal is set once the request was redirected, internal locations
+	// can only handle it from then on.
+	Internal bool
+
+	fs            FileSystem
+	reason        string
+	errorPageDone bool
+	errorStatus   int
+}
+
+// NewRedirects starts following the internal redirects of a request.
+func NewRedirects(opts *MatchOptions) *Redirects {
+	r := &Redirects{Hops: []LocationHop{}, fs: FileList{}}
+	if opts != nil && opts.FileSystem != nil {
+		r.fs = opts.FileSystem
+	}
+	return r
+}
+
+// ErrorStatus is the status an error page is sent with, 0 if the request
+// wasn't redirected to one.
+func (r *Redirects) ErrorStatus() int {
+	return r.errorStatus
+}
+
+// Follow matches the request and follows the internal redirects done by
+// try_files and error_page, the final location is returned with every hop.
+func (s *Scope) Follow(parsedUrl *url.URL, opts *MatchOptions) (*LocationMatcher, error) {
+	r := NewRedirects(opts)
+	target := parsedUrl.Path
+	uri := parsedUrl.Path
+	args := parsedUrl.RawQuery
+
+	for {
+		var match *LocationMatcher
+		var err error
+
+		if strings.HasPrefix(target, "@") {
+			match, err = s.Named(target)
+		} else {
+			uri, args = SplitUri(target, args)
+			match, err = s.Match(uri)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if match == nil {
+			if len(r.Hops) == 0 {
+				return nil, noMatchError(s.RegexErrors())
+			}
+			// the redirect target has no location so nginx answers 404
+			last := r.Hops[len(r.Hops)-1]
+			return &LocationMatcher{
+				MatchPath:    last.MatchPath,
+				MatchModifer: last.MatchModifer,
+				Server:       s.server,
+				Hops:         r.Hops,
+				Uri:          uri,
+				Status:       404,
+				RegexErrors:  s.RegexErrors(),
+			}, nil
+		}
+
+		next, status := s.Visit(r, match, target, uri, args)
+		if next == "" || IsExternalRedirect(next) {
+			match.Hops = r.Hops
+			match.Uri = uri
+			match.RegexErrors = s.RegexErrors()
+			match.Status = status
+			// the error page is sent with the status of the error
+			if status == 0 {
+				match.Status = r.errorStatus
+			}
+			return match, nil
+		}
+
+		target = next
+	}
+}
+
+// Named returns a named location, e.g. @fallback.
+func (s *Scope) Named(name string) (*LocationMatcher, error) {
+	named, ok := s.named[name]
+	if !ok {
+		return nil, fmt.Errorf("could not find named location %q", name)
+	}
+	return &LocationMatcher{
+		MatchPath:    named.Path,
+		MatchModifer: named.Modifier,
+		Directives:   named.Directives,
+		Server:       s.server,
+		Chain:        []LocationMatcher{named.toMatcher()},
+	}, nil
+}
+
+// Visit adds the hop of a request to the location matched for the target,
+// a uri or a named location. It returns the uri, named location or url
+// try_files or error_page redirect the request to, empty if the location
+// handles it, and the status of the response if the location makes one.
+func (s *Scope) Visit(r *Redirects, match *LocationMatcher, target string, uri string, args string) (string, int) {
+	status := 0
+	next := ""
+	nextReason := ""
+
+	if len(r.Hops) > maxUriChanges {
+		status = 500
+	} else if !r.Internal && hasDirective(match.Directives, "internal") {
+		// internal locations can't be reached by external requests
+		status = 404
+	} else if tryFiles, ok := findDirective(match.Directives, "try_files"); ok {
+		next, status = s.tryFiles(match, tryFiles, uri, args, r.fs)
+		nextReason = "try_files"
+	}
+
+	if status != 0 && !r.errorPageDone && len(r.Hops) <= maxUriChanges {
+		if page, code, ok := s.errorPage(match, status); ok {
+			r.errorPageDone = true
+			r.errorStatus = status
+			next = page
+			nextReason = "error_page"
+			if code != 0 {
+				status = code
+				r.errorStatus = code
+			}
+			// only redirect codes can be used with an external page
+			if IsExternalRedirect(page) && !isRedirectStatus(code) {
+				status = 302
+			}
+		}
+	}
+
+	r.Hops = append(r.Hops, LocationHop{
+		Directive:    r.reason,
+		Uri:          target,
+		Status:       status,
+		MatchPath:    match.MatchPath,
+		MatchModifer: match.MatchModifer,
+	})
+
+	if next != "" && !IsExternalRedirect(next) {
+		r.reason = nextReason
+		r.Internal = true
+	}
+	return next, status
+}
+
+// tryFiles checks the files of a try_files directive in order. It returns
+// the uri or named location to redirect to, or the status code to respond
+// with. Both are empty if a file exists and is served by the location.
+func (s *Scope) tryFiles(match *LocationMatcher, tryFiles crossplane.Directive, uri string, args string, fs FileSystem) (string, int) {
+	if len(tryFiles.Args) == 0 {
+		return "", 0
+	}
+
+	root, alias := s.DocumentRoot(match)
+	files := tryFiles.Args[:len(tryFiles.Args)-1]
+	fallback := tryFiles.Args[len(tryFiles.Args)-1]
+
+	for _, file := range files {
+		file = expandTryFilesVars(file, uri, args, root)
+
+		name := root + file
+		if alias != "" && match.MatchModifer != REGEX && match.MatchModifer != REGEX_NO_CASE_SENSITIVE {
+			name = alias + strings.TrimPrefix(file, match.MatchPath)
+		} else if alias != "" {
+			name = alias
+		}
+
+		exists, isDir := fs.Stat(name)
+		if exists && isDir == strings.HasSuffix(file, "/") {
+			return "", 0
+		}
+	}
+
+	if strings.HasPrefix(fallback, "=") {
+		if code, err := strconv.Atoi(fallback[1:]); err == nil {
+			return "", code
+		}
+	}
+
+	return expandTryFilesVars(fallback, uri, args, root), 0
+}
+
+func expandTryFilesVars(s string, uri string, args string, root string) string {
+	isArgs := ""
+	if args != "" {
+		isArgs = "?"
+	}
+
+	return strings.NewReplacer(
+		"$uri", uri,
+		"$document_uri", uri,
+		"$args", args,
+		"$query_string", args,
+		"$is_args", isArgs,
+		"$document_root", root,
+	).Replace(s)
+}
+
+// DocumentRoot returns the root and alias used by the matched location.
+func (s *Scope) DocumentRoot(match *LocationMatcher) (string, string) {
+	for i := len(match.Chain) - 1; i >= 0; i-- {
+		location := match.Chain[i].Directives
+		if alias, ok := findDirective(location, "alias"); ok && len(alias.Args) > 0 {
+			return "", alias.Args[0]
+		}
+		if root, ok := findDirective(location, "root"); ok && len(root.Args) > 0 {
+			return root.Args[0], ""
+		}
+	}
+
+	for _, block := range s.parents() {
+		if root, ok := findDirective(block, "root"); ok && len(root.Args) > 0 {
+			return root.Args[0], ""
+		}
+	}
+
+	return "html", ""
+}
+
+// errorPage finds the error_page for the status. Like nginx, a level only
+// inherits the error pages of its parent if it doesn't define any. It also
+// returns the status code the error page overrides the response with.
+func (s *Scope) errorPage(match *LocationMatcher, status int) (string, int, bool) {
+	levels := []crossplane.Directive{}
+	for i := len(match.Chain) - 1; i >= 0; i-- {
+		levels = append(levels, match.Chain[i].Directives)
+	}
+	levels = append(levels, s.parents()...)
+
+	for _, level := range levels {
+		pages := findDirectives(level, "error_page")
+		if len(pages) == 0 {
+			continue
+		}
+
+		for _, page := range pages {
+			if len(page.Args) < 2 {
+				continue
+			}
+
+			target := page.Args[len(page.Args)-1]
+			override := 0
+			matched := false
+			for _, arg := range page.Args[:len(page.Args)-1] {
+				if strings.HasPrefix(arg, "=") {
+					override, _ = strconv.Atoi(arg[1:])
+					continue
+				}
+				if code, err := strconv.Atoi(arg); err == nil && code == status {
+					matched = true
+				}
+			}
+
+			if matched {
+				return target, override, true
+			}
+		}
+
+		return "", 0, false
+	}
+
+	return "", 0, false
+}
+
+// parents returns the server and http blocks around the locations.
+func (s *Scope) parents() []crossplane.Directive {
+	parents := []crossplane.Directive{}
+	if s.server != nil {
+		parents = append(parents, s.server.Directives)
+	}
+	if s.http != nil {
+		parents = append(parents, *s.http)
+	}
+	return parents
+}
+
+// noMatchError tells which regexes were skipped since one of them might
+// have been the match.
+func noMatchError(regexErrors []RegexError) error {
+	if len(regexErrors) == 0 {
+		return errors.New("no match found")
+	}
+
+	skipped := []string{}
+	for _, e := range regexErrors {
+		skipped = append(skipped, fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Error))
+	}
+	return fmt.Errorf("no match found, skipped regexes that cannot be evaluated: %s", strings.Join(skipped, "; "))
+}
+
+// SplitUri splits the args of a redirect target from its uri, the args of
+// the request are kept if it has none.
+func SplitUri(target string, args string) (string, string) {
+	if idx := strings.Index(target, "?"); idx >= 0 {
+		return target[:idx], target[idx+1:]
+	}
+	return target, args
+}
+
+func isRedirectStatus(code int) bool {
+	return code == 301 || code == 302 || code == 303 || code == 307 || code == 308
+}
+
+// IsExternalRedirect reports whether a redirect target is a url instead of
+// a uri or a named location.
+func IsExternalRedirect(target string) bool {
+	return strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+}
+
+func hasDirective(block crossplane.Directive, name string) bool {
+	_, ok := findDirective(block, name)
+	return ok
+}
+
+// findDirective returns the first directive with the name directly inside
+// the block.
+func findDirective(block crossplane.Directive, name string) (crossplane.Directive, bool) {
+	directives := findDirectives(block, name)
+	if len(directives) == 0 {
+		return crossplane.Directive{}, false
+	}
+	return directives[0], true
+}
+
+// findDirectives returns every directive with the name directly inside the
+// block.
+func findDirectives(block crossplane.Directive, name string) []crossplane.Directive {
+	found := []crossplane.Directive{}
+	if block.Block == nil {
+		return found
+	}
+	for _, d := range *block.Block {
+		if d.Directive == name {
+			found = append(found, d)
+		}
+	}
+	return found
+}
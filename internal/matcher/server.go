@@ -0,0 +1,330 @@
+package matcher
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/internal/pcre"
+)
+
+type ServerMatcher struct {
+	MatchName   string
+	MatchListen string
+	IsDefault   bool
+	Directives  crossplane.Directive
+
+	// Captures of a regex server name, starting with the whole match, and
+	// its named captures.
+	Captures      []string
+	NamedCaptures map[string]string
+}
+
+type serverDirective struct {
+	Names      []string
+	Listens    []listenDirective
+	Directives crossplane.Directive
+}
+
+type listenDirective struct {
+	Addr          string
+	Port          string
+	DefaultServer bool
+	Raw           string
+}
+
+// servers are listening on *:80 when they don't have any listen directive
+var defaultListen = listenDirective{Addr: "", Port: "80", Raw: "*:80"}
+
+// getServers collects the http server blocks, skipping stream and mail ones.
+func getServers(directive []crossplane.Directive, serverDirectives *[]crossplane.Directive) {
+	for _, parsed := range directive {
+		if parsed.Directive == "server" && parsed.Block != nil {
+			*serverDirectives = append(*serverDirectives, parsed)
+			continue
+		}
+
+		switch parsed.Directive {
+		case "stream", "mail", "upstream", "location":
+			continue
+		}
+
+		if parsed.Block != nil {
+			getServers(*parsed.Block, serverDirectives)
+		}
+	}
+}
+
+// PrimaryName returns the first name of the server block, which is what
+// $server_name is set to.
+func (s *ServerMatcher) PrimaryName() string {
+	return newServerDirective(s.Directives).Names[0]
+}
+
+func newServerDirective(directive crossplane.Directive) serverDirective {
+	server := serverDirective{Directives: directive}
+
+	for _, d := range *directive.Block {
+		switch d.Directive {
+		case "server_name":
+			for _, name := range d.Args {
+				// regex names are kept as is to not change their escapes
+				if !strings.HasPrefix(name, "~") {
+					name = strings.ToLower(name)
+				}
+				server.Names = append(server.Names, name)
+			}
+		case "listen":
+			if listen, ok := parseListen(d.Args); ok {
+				server.Listens = append(server.Listens, listen)
+			}
+		}
+	}
+
+	if len(server.Listens) == 0 {
+		server.Listens = []listenDirective{defaultListen}
+	}
+
+	// nginx uses an empty server name if there is none
+	if len(server.Names) == 0 {
+		server.Names = []string{""}
+	}
+
+	return server
+}
+
+// parseListen parses the address and port of a listen directive. Unix
+// sockets are skipped since no url can be sent to them.
+func parseListen(args []string) (listenDirective, bool) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "unix:") {
+		return listenDirective{}, false
+	}
+
+	listen := listenDirective{Port: "80", Raw: args[0]}
+	addr := args[0]
+
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		listen.Addr = host
+		listen.Port = port
+	} else if isPort(addr) {
+		listen.Port = addr
+	} else {
+		listen.Addr = strings.Trim(addr, "[]")
+	}
+
+	if listen.Addr == "*" || listen.Addr == "0.0.0.0" || listen.Addr == "::" {
+		listen.Addr = ""
+	}
+
+	for _, arg := range args[1:] {
+		if arg == "default_server" || arg == "default" {
+			listen.DefaultServer = true
+		}
+	}
+
+	return listen, true
+}
+
+func isPort(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, char := range s {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// matchServer picks the server block that handles the url the way nginx
+// does, first by the listen address and port and then by the server name.
+// It returns nil if there are no server blocks at all.
+func matchServer(serverDirectives []crossplane.Directive, targetUrl *url.URL, src *sources) (*ServerMatcher, error) {
+	if len(serverDirectives) == 0 {
+		return nil, nil
+	}
+
+	port := targetUrl.Port()
+	if port == "" {
+		port = "80"
+		if targetUrl.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(targetUrl.Hostname()), ".")
+
+	// only an ip address tells which address the request was sent to,
+	// otherwise every server listening on the port is a candidate
+	addr := ""
+	if ip := net.ParseIP(host); ip != nil {
+		addr = ip.String()
+	}
+
+	servers := make([]serverDirective, 0)
+	listens := make([]listenDirective, 0)
+	for _, directive := range serverDirectives {
+		server := newServerDirective(directive)
+		if listen, ok := server.listensOn(addr, port, true); ok {
+			servers = append(servers, server)
+			listens = append(listens, listen)
+		}
+	}
+
+	// servers listening on a wildcard address only get requests for an ip
+	// address if no server is listening on that exact address
+	if len(servers) == 0 && addr != "" {
+		for _, directive := range serverDirectives {
+			server := newServerDirective(directive)
+			if listen, ok := server.listensOn(addr, port, false); ok {
+				servers = append(servers, server)
+				listens = append(listens, listen)
+			}
+		}
+	}
+
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no server listening on port %s", port)
+	}
+
+	idx, name, reg, submatches := matchServerName(servers, host, src)
+
+	if idx >= 0 {
+		server := &ServerMatcher{
+			MatchName:   name,
+			MatchListen: listens[idx].Raw,
+			IsDefault:   false,
+			Directives:  servers[idx].Directives,
+		}
+		if reg != nil {
+			server.Captures, server.NamedCaptures = pcre.Captures(reg, submatches)
+		}
+		return server, nil
+	}
+
+	// fallback to the default server or the first one listening on the port
+	idx = 0
+	for i, listen := range listens {
+		if listen.DefaultServer {
+			idx = i
+			break
+		}
+	}
+
+	return &ServerMatcher{
+		MatchName:   "",
+		MatchListen: listens[idx].Raw,
+		IsDefault:   true,
+		Directives:  servers[idx].Directives,
+	}, nil
+}
+
+// listensOn returns the listen directive the server gets requests on. If
+// exact is true only listens on the given address match, otherwise only
+// wildcard ones do.
+func (s serverDirective) listensOn(addr string, port string, exact bool) (listenDirective, bool) {
+	for _, listen := range s.Listens {
+		if listen.Port != port {
+			continue
+		}
+
+		if addr == "" || (exact && sameAddr(listen.Addr, addr)) || (!exact && listen.Addr == "") {
+			return listen, true
+		}
+	}
+	return listenDirective{}, false
+}
+
+func sameAddr(listenAddr string, addr string) bool {
+	ip := net.ParseIP(listenAddr)
+	return ip != nil && ip.String() == addr
+}
+
+// matchServerName returns the index of the server whose name matches host
+// in the order nginx checks them: exact name, longest wildcard starting with
+// an asterisk, longest wildcard ending with an asterisk and then the first
+// matching regex. It returns -1 if no name matches.
+func matchServerName(servers []serverDirective, host string, src *sources) (int, string, *pcre.Regexp, []string) {
+	for i, server := range servers {
+		for _, name := range server.Names {
+			if name == host {
+				return i, name, nil, nil
+			}
+		}
+	}
+
+	bestIdx, bestName := -1, ""
+	for i, server := range servers {
+		for _, name := range server.Names {
+			if MatchLeadingWildcard(name, host) && len(name) > len(bestName) {
+				bestIdx, bestName = i, name
+			}
+		}
+	}
+	if bestIdx >= 0 {
+		return bestIdx, bestName, nil, nil
+	}
+
+	for i, server := range servers {
+		for _, name := range server.Names {
+			if MatchTrailingWildcard(name, host) && len(name) > len(bestName) {
+				bestIdx, bestName = i, name
+			}
+		}
+	}
+	if bestIdx >= 0 {
+		return bestIdx, bestName, nil, nil
+	}
+
+	for i, server := range servers {
+		for _, name := range server.Names {
+			if !strings.HasPrefix(name, "~") {
+				continue
+			}
+
+			reg := src.compile(server.Directives.Block, server.nameLine(name), name[1:], true)
+			if reg == nil {
+				continue
+			}
+
+			if submatches, _ := src.find(server.Directives.Block, server.nameLine(name), reg, host); submatches != nil {
+				return i, name, reg, submatches
+			}
+		}
+	}
+
+	return -1, "", nil, nil
+}
+
+// nameLine returns the line of the server_name directive defining name.
+func (s serverDirective) nameLine(name string) int {
+	for _, d := range *s.Directives.Block {
+		if d.Directive == "server_name" && crossplane.Contains(d.Args, name) {
+			return d.Line
+		}
+	}
+	return s.Directives.Line
+}
+
+// MatchLeadingWildcard matches names like "*.example.com" and the special
+// ".example.com" that also matches "example.com".
+func MatchLeadingWildcard(name string, host string) bool {
+	if strings.HasPrefix(name, "*.") {
+		return strings.HasSuffix(host, name[1:])
+	}
+	if strings.HasPrefix(name, ".") {
+		return host == name[1:] || strings.HasSuffix(host, name)
+	}
+	return false
+}
+
+// MatchTrailingWildcard matches names like "www.example.*".
+func MatchTrailingWildcard(name string, host string) bool {
+	if !strings.HasSuffix(name, ".*") {
+		return false
+	}
+	return strings.HasPrefix(host, name[:len(name)-1])
+}
@@ -0,0 +1,84 @@
+package matcher
+
+import (
+	"fmt"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/internal/pcre"
+)
+
+// RegexError is a regex of the config that can't be evaluated, it is
+// skipped instead of failing the whole match.
+type RegexError struct {
+	File  string
+	Line  int
+	Regex string
+	Error string
+}
+
+// sources remembers the file every block and directive of the inlined config
+// was parsed from, so errors can point at the right file.
+type sources struct {
+	inlined     *crossplane.Inlined
+	regexErrors []RegexError
+	seen        map[string]bool
+}
+
+// inlineConfigs replaces the include directives of an uncombined payload
+// with the directives of the included files while keeping track of the file
+// of every block.
+func inlineConfigs(conf *crossplane.Payload) ([]crossplane.Directive, *sources, error) {
+	inlined, err := crossplane.Inline(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return inlined.Parsed, &sources{inlined: inlined, seen: map[string]bool{}}, nil
+}
+
+// file returns the file a block was parsed from.
+func (src *sources) file(block *[]crossplane.Directive) string {
+	return src.inlined.File(block)
+}
+
+// entry returns the file the directive at index idx of a block was parsed
+// from, which differs from the file of the block when it was included.
+func (src *sources) entry(block *[]crossplane.Directive, idx int) string {
+	return src.inlined.DirectiveFile(block, idx)
+}
+
+// compile compiles a regex of a directive whose block is given, recording
+// why it can't be evaluated if it fails.
+func (src *sources) compile(block *[]crossplane.Directive, line int, expr string, caseless bool) *pcre.Regexp {
+	reg, err := pcre.Compile(expr, caseless)
+	if err == nil {
+		return reg
+	}
+
+	src.report(block, line, expr, err)
+	return nil
+}
+
+// find matches a compiled regex of a directive found in the block, recording
+// why it can't be evaluated if the subject is given up on.
+func (src *sources) find(block *[]crossplane.Directive, line int, reg *pcre.Regexp, subject string) ([]string, error) {
+	submatches, err := reg.FindStringSubmatch(subject)
+	if err != nil {
+		src.report(block, line, reg.String(), err)
+	}
+	return submatches, err
+}
+
+func (src *sources) report(block *[]crossplane.Directive, line int, expr string, err error) {
+	file := src.file(block)
+	key := fmt.Sprintf("%s:%d:%s", file, line, expr)
+	if !src.seen[key] {
+		src.seen[key] = true
+		src.regexErrors = append(src.regexErrors, RegexError{
+			File:  file,
+			Line:  line,
+			Regex: expr,
+			Error: err.Error(),
+		})
+	}
+}
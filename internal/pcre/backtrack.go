@@ -0,0 +1,287 @@
+package pcre
+
+import (
+	"errors"
+	"unicode"
+)
+
+// the backtracking engine gives up on a subject after this many steps so a
+// catastrophic pattern can't hang the caller
+const maxSteps = 1000000
+
+// program is a pattern compiled for the backtracking engine, used for the
+// PCRE features Go's regexp doesn't have like lookarounds, backreferences,
+// atomic groups and possessive quantifiers.
+type program struct {
+	root   *node
+	ngroup int
+}
+
+type machine struct {
+	input []rune
+	caps  []int
+	steps int
+}
+
+// errStepLimit is returned when the engine gives up on a subject.
+var errStepLimit = errors.New("backtracking step limit exceeded")
+
+// find returns the capture positions of the leftmost match in the subject
+// as rune offsets, or nil if there is no match.
+func (prog *program) find(subject string) ([]int, []rune, error) {
+	input := []rune(subject)
+	m := &machine{input: input}
+
+	for start := 0; start <= len(input); start++ {
+		m.caps = make([]int, 2*(prog.ngroup+1))
+		for i := range m.caps {
+			m.caps[i] = -1
+		}
+
+		end := -1
+		if m.match(prog.root, start, func(i int) bool {
+			end = i
+			return true
+		}) {
+			m.caps[0], m.caps[1] = start, end
+			return m.caps, input, nil
+		}
+
+		if m.steps > maxSteps {
+			return nil, input, errStepLimit
+		}
+	}
+
+	return nil, input, nil
+}
+
+// match matches n at position i and calls k with the position after it,
+// backtracking into n while k fails.
+func (m *machine) match(n *node, i int, k func(int) bool) bool {
+	m.steps++
+	if m.steps > maxSteps {
+		return false
+	}
+
+	switch n.kind {
+	case nodeLiteral:
+		if i < len(m.input) && sameChar(m.input[i], n.char, n.flags.caseless) {
+			return k(i + 1)
+		}
+		return false
+
+	case nodeAny:
+		if i < len(m.input) && (n.flags.dotAll || m.input[i] != '\n') {
+			return k(i + 1)
+		}
+		return false
+
+	case nodeClass:
+		if i >= len(m.input) {
+			return false
+		}
+		if n.flags.caseless && n.class.matchesFold(m.input[i]) || !n.flags.caseless && n.class.matches(m.input[i]) {
+			return k(i + 1)
+		}
+		return false
+
+	case nodeLineStart:
+		if i == 0 || (n.flags.multiline && m.input[i-1] == '\n') {
+			return k(i)
+		}
+		return false
+
+	case nodeLineEnd:
+		// like PCRE, $ also matches before a newline at the end
+		if i == len(m.input) || (i == len(m.input)-1 && m.input[i] == '\n') ||
+			(n.flags.multiline && m.input[i] == '\n') {
+			return k(i)
+		}
+		return false
+
+	case nodeTextStart:
+		if i == 0 {
+			return k(i)
+		}
+		return false
+
+	case nodeTextEnd:
+		if i == len(m.input) {
+			return k(i)
+		}
+		return false
+
+	case nodeTextEndNewline:
+		if i == len(m.input) || (i == len(m.input)-1 && m.input[i] == '\n') {
+			return k(i)
+		}
+		return false
+
+	case nodeWordBoundary, nodeNotWordBoundary:
+		before := i > 0 && isWord(m.input[i-1])
+		after := i < len(m.input) && isWord(m.input[i])
+		if (before != after) == (n.kind == nodeWordBoundary) {
+			return k(i)
+		}
+		return false
+
+	case nodeConcat:
+		return m.matchConcat(n.children, 0, i, k)
+
+	case nodeAlternate:
+		for _, child := range n.children {
+			if m.match(child, i, k) {
+				return true
+			}
+		}
+		return false
+
+	case nodeRepeat:
+		if n.possessive {
+			return m.matchAtomic(&node{kind: nodeRepeat, children: n.children, min: n.min, max: n.max}, i, k)
+		}
+		return m.matchRepeat(n, 0, i, k)
+
+	case nodeGroup:
+		if n.index < 0 {
+			return m.match(n.children[0], i, k)
+		}
+		return m.match(n.children[0], i, func(j int) bool {
+			start, end := m.caps[2*n.index], m.caps[2*n.index+1]
+			m.caps[2*n.index], m.caps[2*n.index+1] = i, j
+			if k(j) {
+				return true
+			}
+			m.caps[2*n.index], m.caps[2*n.index+1] = start, end
+			return false
+		})
+
+	case nodeAtomic:
+		return m.matchAtomic(n.children[0], i, k)
+
+	case nodeLookahead:
+		saved := append([]int(nil), m.caps...)
+		found := m.match(n.children[0], i, func(int) bool { return true })
+		if found == n.negate {
+			m.caps = saved
+			return false
+		}
+		if n.negate {
+			m.caps = saved
+		}
+		if k(i) {
+			return true
+		}
+		m.caps = saved
+		return false
+
+	case nodeLookbehind:
+		saved := append([]int(nil), m.caps...)
+		found := false
+		for start := i; start >= 0 && !found; start-- {
+			found = m.match(n.children[0], start, func(j int) bool { return j == i })
+		}
+		if found == n.negate {
+			m.caps = saved
+			return false
+		}
+		if n.negate {
+			m.caps = saved
+		}
+		if k(i) {
+			return true
+		}
+		m.caps = saved
+		return false
+
+	case nodeBackref:
+		start, end := m.caps[2*n.index], m.caps[2*n.index+1]
+		if start < 0 {
+			return false
+		}
+		j := i
+		for _, r := range m.input[start:end] {
+			if j >= len(m.input) || !sameChar(m.input[j], r, n.flags.caseless) {
+				return false
+			}
+			j++
+		}
+		return k(j)
+	}
+
+	return false
+}
+
+func (m *machine) matchConcat(children []*node, idx int, i int, k func(int) bool) bool {
+	if idx == len(children) {
+		return k(i)
+	}
+	return m.match(children[idx], i, func(j int) bool {
+		return m.matchConcat(children, idx+1, j, k)
+	})
+}
+
+func (m *machine) matchRepeat(n *node, count int, i int, k func(int) bool) bool {
+	child := n.children[0]
+
+	if count < n.min {
+		return m.match(child, i, func(j int) bool {
+			return m.matchRepeat(n, count+1, j, k)
+		})
+	}
+
+	canRepeat := n.max < 0 || count < n.max
+
+	// an empty iteration would repeat forever, so it ends the loop
+	next := func(j int) bool {
+		if j == i {
+			return false
+		}
+		return m.matchRepeat(n, count+1, j, k)
+	}
+
+	if n.lazy {
+		if k(i) {
+			return true
+		}
+		return canRepeat && m.match(child, i, next)
+	}
+
+	if canRepeat && m.match(child, i, next) {
+		return true
+	}
+	return k(i)
+}
+
+// matchAtomic matches n once without ever backtracking into it.
+func (m *machine) matchAtomic(n *node, i int, k func(int) bool) bool {
+	end := -1
+	saved := append([]int(nil), m.caps...)
+	if !m.match(n, i, func(j int) bool {
+		end = j
+		return true
+	}) {
+		return false
+	}
+
+	if k(end) {
+		return true
+	}
+	m.caps = saved
+	return false
+}
+
+func sameChar(a rune, b rune, caseless bool) bool {
+	if a == b {
+		return true
+	}
+	if !caseless {
+		return false
+	}
+	for f := unicode.SimpleFold(b); f != b; f = unicode.SimpleFold(f) {
+		if f == a {
+			return true
+		}
+	}
+	return false
+}
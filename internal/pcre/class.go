@@ -0,0 +1,110 @@
+package pcre
+
+import "unicode"
+
+type charClass struct {
+	negate bool
+	ranges [][2]rune
+	funcs  []func(rune) bool
+}
+
+func (c *charClass) matches(r rune) bool {
+	return c.contains(r) != c.negate
+}
+
+func (c *charClass) contains(r rune) bool {
+	for _, rng := range c.ranges {
+		if r >= rng[0] && r <= rng[1] {
+			return true
+		}
+	}
+	for _, fn := range c.funcs {
+		if fn(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFold matches r ignoring its case.
+func (c *charClass) matchesFold(r rune) bool {
+	if c.contains(r) {
+		return !c.negate
+	}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if c.contains(f) {
+			return !c.negate
+		}
+	}
+	return c.negate
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isWord(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || isDigit(r)
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' || r == '\v'
+}
+
+func isHorizontalSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == 0xa0
+}
+
+func isVerticalSpace(r rune) bool {
+	return r == '\n' || r == '\v' || r == '\f' || r == '\r' || r == 0x85 || r == 0x2028 || r == 0x2029
+}
+
+func negateFunc(fn func(rune) bool) func(rune) bool {
+	return func(r rune) bool {
+		return !fn(r)
+	}
+}
+
+// escapeClass returns the class of an escape like \d or \w.
+func escapeClass(c rune) (*charClass, bool) {
+	var fn func(rune) bool
+
+	switch c {
+	case 'd', 'D':
+		fn = isDigit
+	case 'w', 'W':
+		fn = isWord
+	case 's', 'S':
+		fn = isSpace
+	case 'h', 'H':
+		fn = isHorizontalSpace
+	case 'v', 'V':
+		fn = isVerticalSpace
+	default:
+		return nil, false
+	}
+
+	if unicode.IsUpper(c) {
+		fn = negateFunc(fn)
+	}
+	return &charClass{funcs: []func(rune) bool{fn}}, true
+}
+
+var posixClasses = map[string]func(rune) bool{
+	"alnum": func(r rune) bool { return r < 128 && (unicode.IsLetter(r) || isDigit(r)) },
+	"alpha": func(r rune) bool { return r < 128 && unicode.IsLetter(r) },
+	"ascii": func(r rune) bool { return r < 128 },
+	"blank": func(r rune) bool { return r == ' ' || r == '\t' },
+	"cntrl": func(r rune) bool { return r < 32 || r == 127 },
+	"digit": isDigit,
+	"graph": func(r rune) bool { return r > 32 && r < 127 },
+	"lower": func(r rune) bool { return r >= 'a' && r <= 'z' },
+	"print": func(r rune) bool { return r >= 32 && r < 127 },
+	"punct": func(r rune) bool { return (r > 32 && r < 127 && !isWord(r)) || r == '_' },
+	"space": isSpace,
+	"upper": func(r rune) bool { return r >= 'A' && r <= 'Z' },
+	"word":  isWord,
+	"xdigit": func(r rune) bool {
+		return isDigit(r) || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+	},
+}
@@ -0,0 +1,158 @@
+package pcre
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Regexp is a regex used by nginx, e.g. in a location, server_name or
+// rewrite. It runs on Go's regexp when the pattern can be translated to its
+// syntax and on a backtracking engine otherwise.
+type Regexp struct {
+	expr  string
+	std   *regexp.Regexp
+	prog  *program
+	names []string
+}
+
+// Error is returned for a pattern neither engine can evaluate, or a subject
+// the backtracking engine gives up on.
+type Error struct {
+	Expr   string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return "cannot evaluate regex " + e.Expr + ": " + e.Reason
+}
+
+// Compile compiles a PCRE pattern, caseless is used for "~*" and server
+// names.
+func Compile(pattern string, caseless bool) (*Regexp, error) {
+	translated := Translate(pattern)
+	if caseless {
+		translated = "(?i)" + translated
+	}
+
+	if std, err := regexp.Compile(translated); err == nil {
+		return &Regexp{expr: pattern, std: std, names: std.SubexpNames()}, nil
+	}
+
+	root, names, err := parse(pattern, caseless)
+	if err != nil {
+		return nil, &Error{Expr: pattern, Reason: err.Error()}
+	}
+
+	return &Regexp{
+		expr:  pattern,
+		prog:  &program{root: root, ngroup: len(names) - 1},
+		names: names,
+	}, nil
+}
+
+// String returns the source pattern.
+func (r *Regexp) String() string {
+	return r.expr
+}
+
+// Backtracking reports whether the pattern runs on the backtracking engine.
+func (r *Regexp) Backtracking() bool {
+	return r.prog != nil
+}
+
+// SubexpNames returns the names of the capture groups, the first one is
+// always empty for the whole match.
+func (r *Regexp) SubexpNames() []string {
+	return r.names
+}
+
+// MatchString reports whether s contains a match.
+func (r *Regexp) MatchString(s string) (bool, error) {
+	submatches, err := r.FindStringSubmatch(s)
+	return submatches != nil, err
+}
+
+// FindStringSubmatch returns the leftmost match and its captures, unset
+// captures are empty. An *Error is returned if the backtracking engine gives
+// up on s, which is neither a match nor a miss.
+func (r *Regexp) FindStringSubmatch(s string) ([]string, error) {
+	if r.std != nil {
+		return r.std.FindStringSubmatch(s), nil
+	}
+
+	caps, input, err := r.prog.find(s)
+	if err != nil {
+		return nil, &Error{Expr: r.expr, Reason: err.Error()}
+	}
+	if caps == nil {
+		return nil, nil
+	}
+
+	submatches := make([]string, len(caps)/2)
+	for i := range submatches {
+		if caps[2*i] >= 0 {
+			submatches[i] = string(input[caps[2*i]:caps[2*i+1]])
+		}
+	}
+	return submatches, nil
+}
+
+// Translate rewrites the named groups "(?<name>...)" and "(?'name'...)" of
+// PCRE into the "(?P<name>...)" form accepted by Go.
+func Translate(pattern string) string {
+	var sb strings.Builder
+	inClass := false
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			sb.WriteString(pattern[i : i+2])
+			i++
+			continue
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+			// a "]" right after the opening bracket is a literal
+			if strings.HasPrefix(pattern[i+1:], "]") {
+				sb.WriteString("[]")
+				i++
+				continue
+			}
+			if strings.HasPrefix(pattern[i+1:], "^]") {
+				sb.WriteString("[^]")
+				i += 2
+				continue
+			}
+		case strings.HasPrefix(pattern[i:], "(?<") && !strings.HasPrefix(pattern[i:], "(?<=") && !strings.HasPrefix(pattern[i:], "(?<!"):
+			sb.WriteString("(?P<")
+			i += 2
+			continue
+		case strings.HasPrefix(pattern[i:], "(?'"):
+			if end := strings.IndexByte(pattern[i+3:], '\''); end >= 0 {
+				sb.WriteString("(?P<" + pattern[i+3:i+3+end] + ">")
+				i += 3 + end
+				continue
+			}
+		}
+
+		sb.WriteByte(c)
+	}
+
+	return sb.String()
+}
+
+// Captures returns the positional and named captures of a match.
+func Captures(reg *Regexp, submatches []string) ([]string, map[string]string) {
+	named := map[string]string{}
+	for i, name := range reg.SubexpNames() {
+		if name != "" && i < len(submatches) {
+			named[name] = submatches[i]
+		}
+	}
+	return submatches, named
+}
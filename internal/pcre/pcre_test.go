@@ -0,0 +1,134 @@
+package pcre
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{`^/api/(?<version>v\d+)/`, `^/api/(?P<version>v\d+)/`},
+		{`^/(?'name'[a-z]+)$`, `^/(?P<name>[a-z]+)$`},
+		{`(?<=/)foo(?<!bar)`, `(?<=/)foo(?<!bar)`},
+		{`[(?<x>)]`, `[(?<x>)]`},
+		{`\(?<x>`, `\(?<x>`},
+		{`[]a]`, `[]a]`},
+		{`[^]a]`, `[^]a]`},
+	}
+
+	for _, tt := range tests {
+		if got := Translate(tt.pattern); got != tt.want {
+			t.Errorf("Translate(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	for _, pattern := range []string{`(abc`, `a**`, `\k<missing>`, `(?<=a`} {
+		if _, err := Compile(pattern, false); err == nil {
+			t.Errorf("Compile(%q) succeeded, want an error", pattern)
+		} else if _, ok := err.(*Error); !ok {
+			t.Errorf("Compile(%q) error is %T, want *Error", pattern, err)
+		}
+	}
+}
+
+func TestFindStringSubmatch(t *testing.T) {
+	tests := []struct {
+		pattern      string
+		caseless     bool
+		subject      string
+		want         []string
+		backtracking bool
+	}{
+		// Go's regexp
+		{`^/api/(v\d+)/`, false, "/api/v2/users", []string{"/api/v2/", "v2"}, false},
+		{`^/api/(?<version>v\d+)/`, false, "/api/v2/", []string{"/api/v2/", "v2"}, false},
+		{`\.(png|jpg)$`, true, "/IMG.PNG", []string{".PNG", "PNG"}, false},
+		{`\.(png|jpg)$`, false, "/IMG.PNG", nil, false},
+		{`^/(a)?b`, false, "/b", []string{"/b", ""}, false},
+
+		// backtracking engine
+		{`^/(?!admin/)(\w+)/`, false, "/users/1", []string{"/users/", "users"}, true},
+		{`^/(?!admin/)(\w+)/`, false, "/admin/1", nil, true},
+		{`^/(?=[a-z]+/)([a-z]+)`, false, "/abc/", []string{"/abc", "abc"}, true},
+		{`(?<=/v)\d+`, false, "/v12/", []string{"12"}, true},
+		{`(?<!/v)\b\d+`, false, "/v12/34", []string{"34"}, true},
+		{`^/(\w+)/\1$`, false, "/abc/abc", []string{"/abc/abc", "abc"}, true},
+		{`^/(\w+)/\1$`, false, "/abc/abd", nil, true},
+		{`^/(?<name>\w+)/\k<name>$`, false, "/ab/ab", []string{"/ab/ab", "ab"}, true},
+		{`^/(?<name>\w+)/\k<name>$`, true, "/ab/AB", []string{"/ab/AB", "ab"}, true},
+		{`^(?>a+)ab`, false, "aaab", nil, true},
+		{`^a++b`, false, "aaab", []string{"aaab"}, true},
+		{`^a++ab`, false, "aaab", nil, true},
+		{`^(?=(a+))a*b\1`, false, "aaabaaa", []string{"aaabaaa", "aaa"}, true},
+		{`\Aab\z(?!x)`, false, "ab", []string{"ab"}, true},
+		{`(?i)ab(?-i)c(?=.)`, false, "ABcd", []string{"ABc"}, true},
+		{`(?i)ab(?-i)c(?=.)`, false, "ABCd", nil, true},
+		{`^(?!x)a$`, false, "a\n", []string{"a"}, true},
+		{`(?!x)(\d+)(?:px)?`, false, "w=10px", []string{"10px", "10"}, true},
+		{`(?<!^)é+(?=$)`, false, "aéé", []string{"éé"}, true},
+	}
+
+	for _, tt := range tests {
+		reg, err := Compile(tt.pattern, tt.caseless)
+		if err != nil {
+			t.Errorf("Compile(%q) error: %v", tt.pattern, err)
+			continue
+		}
+		if reg.Backtracking() != tt.backtracking {
+			t.Errorf("Compile(%q).Backtracking() = %v, want %v", tt.pattern, reg.Backtracking(), tt.backtracking)
+		}
+
+		got, err := reg.FindStringSubmatch(tt.subject)
+		if err != nil {
+			t.Errorf("%q.FindStringSubmatch(%q) error: %v", tt.pattern, tt.subject, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q.FindStringSubmatch(%q) = %q, want %q", tt.pattern, tt.subject, got, tt.want)
+		}
+	}
+}
+
+func TestCaptures(t *testing.T) {
+	reg, err := Compile(`^/(?<lang>[a-z]{2})/(?!x)(.*)$`, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submatches, err := reg.FindStringSubmatch("/en/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	captures, named := Captures(reg, submatches)
+	if !reflect.DeepEqual(captures, []string{"/en/docs", "en", "docs"}) {
+		t.Errorf("captures = %q", captures)
+	}
+	if !reflect.DeepEqual(named, map[string]string{"lang": "en"}) {
+		t.Errorf("named captures = %q", named)
+	}
+}
+
+func TestStepLimit(t *testing.T) {
+	reg, err := Compile(`^/(?=(a+)+c)`, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submatches, err := reg.FindStringSubmatch("/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	if submatches != nil {
+		t.Errorf("submatches = %q, want nil", submatches)
+	}
+	if _, ok := err.(*Error); !ok {
+		t.Fatalf("error = %v, want a *Error", err)
+	}
+
+	matched, err := reg.MatchString("/aac")
+	if err != nil || !matched {
+		t.Errorf("MatchString(/aac) = %v, %v, want true", matched, err)
+	}
+}
@@ -0,0 +1,642 @@
+package pcre
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type nodeKind int
+
+const (
+	nodeLiteral nodeKind = iota
+	nodeAny
+	nodeClass
+	nodeLineStart
+	nodeLineEnd
+	nodeTextStart
+	nodeTextEnd
+	nodeTextEndNewline
+	nodeWordBoundary
+	nodeNotWordBoundary
+	nodeConcat
+	nodeAlternate
+	nodeRepeat
+	nodeGroup
+	nodeLookahead
+	nodeLookbehind
+	nodeAtomic
+	nodeBackref
+)
+
+type flags struct {
+	caseless  bool
+	dotAll    bool
+	multiline bool
+}
+
+// node is a parsed regex for the backtracking engine.
+type node struct {
+	kind     nodeKind
+	char     rune
+	class    *charClass
+	children []*node
+	flags    flags
+
+	// capture index of a group or backreference, -1 for other groups
+	index int
+	name  string
+
+	// repeat bounds, max is -1 if unbounded
+	min, max   int
+	lazy       bool
+	possessive bool
+
+	// negative lookaround
+	negate bool
+}
+
+type parser struct {
+	src    []rune
+	pos    int
+	ngroup int
+	names  []string
+	refs   []*node
+}
+
+// parse parses a PCRE pattern into a tree for the backtracking engine. It
+// returns the tree and the names of its capture groups by index.
+func parse(pattern string, caseless bool) (*node, []string, error) {
+	p := &parser{src: []rune(pattern), names: []string{""}}
+
+	root, err := p.parseAlternate(flags{caseless: caseless})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if p.pos < len(p.src) {
+		return nil, nil, fmt.Errorf("unmatched ) at position %d", p.pos)
+	}
+
+	// named backreferences can only be resolved once every group is known
+	for _, ref := range p.refs {
+		if ref.name != "" {
+			ref.index = -1
+			for i, name := range p.names {
+				if name == ref.name {
+					ref.index = i
+				}
+			}
+			if ref.index < 0 {
+				return nil, nil, fmt.Errorf("reference to non-existent subpattern %q", ref.name)
+			}
+		}
+		if ref.index > p.ngroup {
+			return nil, nil, fmt.Errorf("reference to non-existent subpattern %d", ref.index)
+		}
+	}
+
+	return root, p.names, nil
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() rune {
+	return p.src[p.pos]
+}
+
+func (p *parser) lookingAt(s string) bool {
+	return strings.HasPrefix(string(p.src[p.pos:]), s)
+}
+
+func (p *parser) parseAlternate(f flags) (*node, error) {
+	branches := []*node{}
+
+	for {
+		branch, next, err := p.parseConcat(f)
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, branch)
+		f = next
+
+		if p.eof() || p.peek() != '|' {
+			break
+		}
+		p.pos++
+	}
+
+	if len(branches) == 1 {
+		return branches[0], nil
+	}
+	return &node{kind: nodeAlternate, children: branches}, nil
+}
+
+// parseConcat parses a branch, it returns the flags in effect at its end
+// since inline flags like "(?i)" last until the end of the enclosing group.
+func (p *parser) parseConcat(f flags) (*node, flags, error) {
+	items := []*node{}
+
+	for !p.eof() && p.peek() != '|' && p.peek() != ')' {
+		if p.lookingAt(`\Q`) {
+			p.pos += 2
+			for !p.eof() && !p.lookingAt(`\E`) {
+				items = append(items, &node{kind: nodeLiteral, char: p.peek(), flags: f})
+				p.pos++
+			}
+			if !p.eof() {
+				p.pos += 2
+			}
+			continue
+		}
+
+		atom, next, err := p.parseAtom(f)
+		if err != nil {
+			return nil, f, err
+		}
+		f = next
+		if atom == nil {
+			continue
+		}
+
+		atom, err = p.parseQuantifier(atom)
+		if err != nil {
+			return nil, f, err
+		}
+		items = append(items, atom)
+	}
+
+	if len(items) == 1 {
+		return items[0], f, nil
+	}
+	return &node{kind: nodeConcat, children: items}, f, nil
+}
+
+func (p *parser) parseQuantifier(atom *node) (*node, error) {
+	if p.eof() {
+		return atom, nil
+	}
+
+	min, max := 0, 0
+	switch p.peek() {
+	case '*':
+		min, max = 0, -1
+		p.pos++
+	case '+':
+		min, max = 1, -1
+		p.pos++
+	case '?':
+		min, max = 0, 1
+		p.pos++
+	case '{':
+		var ok bool
+		min, max, ok = p.parseBraces()
+		if !ok {
+			return atom, nil
+		}
+	default:
+		return atom, nil
+	}
+
+	switch atom.kind {
+	case nodeLineStart, nodeLineEnd, nodeTextStart, nodeTextEnd, nodeTextEndNewline, nodeWordBoundary, nodeNotWordBoundary:
+		return nil, fmt.Errorf("nothing to repeat at position %d", p.pos-1)
+	}
+
+	repeat := &node{kind: nodeRepeat, children: []*node{atom}, min: min, max: max}
+	if !p.eof() && p.peek() == '?' {
+		repeat.lazy = true
+		p.pos++
+	} else if !p.eof() && p.peek() == '+' {
+		repeat.possessive = true
+		p.pos++
+	}
+
+	if !p.eof() && strings.ContainsRune("*+?", p.peek()) {
+		return nil, fmt.Errorf("nothing to repeat at position %d", p.pos)
+	}
+
+	return repeat, nil
+}
+
+// parseBraces parses a {n}, {n,} or {n,m} quantifier. Like PCRE, anything
+// else is not a quantifier and the brace is a literal.
+func (p *parser) parseBraces() (int, int, bool) {
+	rest := string(p.src[p.pos:])
+	end := strings.IndexByte(rest, '}')
+	if end < 0 {
+		return 0, 0, false
+	}
+
+	body := rest[1:end]
+	parts := strings.SplitN(body, ",", 2)
+	min, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	max := min
+	if len(parts) == 2 {
+		if parts[1] == "" {
+			max = -1
+		} else if max, err = strconv.Atoi(parts[1]); err != nil || max < min {
+			return 0, 0, false
+		}
+	}
+
+	p.pos += len([]rune(rest[:end+1]))
+	return min, max, true
+}
+
+func (p *parser) parseAtom(f flags) (*node, flags, error) {
+	c := p.peek()
+	p.pos++
+
+	switch c {
+	case '.':
+		return &node{kind: nodeAny, flags: f}, f, nil
+	case '^':
+		return &node{kind: nodeLineStart, flags: f}, f, nil
+	case '$':
+		return &node{kind: nodeLineEnd, flags: f}, f, nil
+	case '[':
+		class, err := p.parseClass()
+		if err != nil {
+			return nil, f, err
+		}
+		return &node{kind: nodeClass, class: class, flags: f}, f, nil
+	case '(':
+		return p.parseGroup(f)
+	case '\\':
+		n, err := p.parseEscape(f)
+		return n, f, err
+	case '*', '+', '?':
+		return nil, f, fmt.Errorf("nothing to repeat at position %d", p.pos-1)
+	}
+
+	return &node{kind: nodeLiteral, char: c, flags: f}, f, nil
+}
+
+func (p *parser) parseGroup(f flags) (*node, flags, error) {
+	start := p.pos - 1
+	outer := f
+	group := &node{kind: nodeGroup, index: -1, flags: f}
+
+	if p.lookingAt("?") {
+		p.pos++
+
+		switch {
+		case p.lookingAt("#"):
+			end := strings.IndexRune(string(p.src[p.pos:]), ')')
+			if end < 0 {
+				return nil, f, fmt.Errorf("missing ) at position %d", start)
+			}
+			p.pos += len([]rune(string(p.src[p.pos:])[:end])) + 1
+			return nil, f, nil
+		case p.lookingAt(":"):
+			p.pos++
+		case p.lookingAt("="), p.lookingAt("!"):
+			group.kind = nodeLookahead
+			group.negate = p.peek() == '!'
+			p.pos++
+		case p.lookingAt("<="), p.lookingAt("<!"):
+			group.kind = nodeLookbehind
+			group.negate = p.src[p.pos+1] == '!'
+			p.pos += 2
+		case p.lookingAt(">"):
+			group.kind = nodeAtomic
+			p.pos++
+		case p.lookingAt("P="):
+			p.pos += 2
+			name, err := p.parseName(')')
+			if err != nil {
+				return nil, f, err
+			}
+			ref := &node{kind: nodeBackref, name: name, flags: f}
+			p.refs = append(p.refs, ref)
+			return ref, f, nil
+		case p.lookingAt("P<"), p.lookingAt("<"), p.lookingAt("'"):
+			if p.lookingAt("P") {
+				p.pos++
+			}
+			closing := '>'
+			if p.peek() == '\'' {
+				closing = '\''
+			}
+			p.pos++
+			name, err := p.parseName(closing)
+			if err != nil {
+				return nil, f, err
+			}
+			p.ngroup++
+			group.index = p.ngroup
+			p.names = append(p.names, name)
+		default:
+			// inline flags, either "(?i)" for the rest of the group or
+			// "(?i:...)" for a group
+			next, scoped, err := p.parseFlags(f)
+			if err != nil {
+				return nil, f, err
+			}
+			if !scoped {
+				return nil, next, nil
+			}
+			f = next
+			group.flags = f
+		}
+	} else {
+		p.ngroup++
+		group.index = p.ngroup
+		p.names = append(p.names, "")
+	}
+
+	child, err := p.parseAlternate(f)
+	if err != nil {
+		return nil, f, err
+	}
+
+	if p.eof() || p.peek() != ')' {
+		return nil, f, fmt.Errorf("missing ) at position %d", start)
+	}
+	p.pos++
+
+	group.children = []*node{child}
+	return group, outer, nil
+}
+
+// parseFlags parses inline option letters. It reports whether they are
+// scoped to a group, in which case the ":" has been consumed.
+func (p *parser) parseFlags(f flags) (flags, bool, error) {
+	enable := true
+	for !p.eof() {
+		c := p.peek()
+		p.pos++
+
+		switch c {
+		case 'i':
+			f.caseless = enable
+		case 's':
+			f.dotAll = enable
+		case 'm':
+			f.multiline = enable
+		case '-':
+			enable = false
+		case ')':
+			return f, false, nil
+		case ':':
+			return f, true, nil
+		default:
+			return f, false, fmt.Errorf("unsupported group syntax (?%c at position %d", c, p.pos-1)
+		}
+	}
+	return f, false, fmt.Errorf("missing ) at end of pattern")
+}
+
+func (p *parser) parseName(closing rune) (string, error) {
+	start := p.pos
+	for !p.eof() && p.peek() != closing {
+		c := p.peek()
+		if c != '_' && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return "", fmt.Errorf("invalid group name at position %d", p.pos)
+		}
+		p.pos++
+	}
+	if p.eof() || p.pos == start {
+		return "", fmt.Errorf("invalid group name at position %d", start)
+	}
+	name := string(p.src[start:p.pos])
+	p.pos++
+	return name, nil
+}
+
+func (p *parser) parseEscape(f flags) (*node, error) {
+	if p.eof() {
+		return nil, fmt.Errorf(`\ at end of pattern`)
+	}
+
+	c := p.peek()
+	p.pos++
+
+	switch c {
+	case 'A':
+		return &node{kind: nodeTextStart}, nil
+	case 'z':
+		return &node{kind: nodeTextEnd}, nil
+	case 'Z':
+		return &node{kind: nodeTextEndNewline}, nil
+	case 'b':
+		return &node{kind: nodeWordBoundary}, nil
+	case 'B':
+		return &node{kind: nodeNotWordBoundary}, nil
+	case 'k':
+		if p.eof() {
+			return nil, fmt.Errorf(`\k is not followed by a name`)
+		}
+		closing := map[rune]rune{'<': '>', '\'': '\'', '{': '}'}[p.peek()]
+		if closing == 0 {
+			return nil, fmt.Errorf(`\k is not followed by a name`)
+		}
+		p.pos++
+		name, err := p.parseName(closing)
+		if err != nil {
+			return nil, err
+		}
+		ref := &node{kind: nodeBackref, name: name, flags: f}
+		p.refs = append(p.refs, ref)
+		return ref, nil
+	case 'g':
+		ref := &node{kind: nodeBackref, flags: f}
+		braced := !p.eof() && p.peek() == '{'
+		if braced {
+			p.pos++
+		}
+		start := p.pos
+		for !p.eof() && p.peek() != '}' && (braced || unicode.IsDigit(p.peek())) {
+			p.pos++
+		}
+		value := string(p.src[start:p.pos])
+		if braced {
+			if p.eof() {
+				return nil, fmt.Errorf(`\g is not followed by a reference`)
+			}
+			p.pos++
+		}
+		if idx, err := strconv.Atoi(value); err == nil && idx > 0 {
+			ref.index = idx
+		} else if value != "" && braced {
+			ref.name = value
+		} else {
+			return nil, fmt.Errorf(`unsupported \g reference %q`, value)
+		}
+		p.refs = append(p.refs, ref)
+		return ref, nil
+	}
+
+	if c >= '1' && c <= '9' {
+		ref := &node{kind: nodeBackref, index: int(c - '0'), flags: f}
+		p.refs = append(p.refs, ref)
+		return ref, nil
+	}
+
+	if class, ok := escapeClass(c); ok {
+		return &node{kind: nodeClass, class: class, flags: f}, nil
+	}
+
+	char, err := p.escapeChar(c)
+	if err != nil {
+		return nil, err
+	}
+	return &node{kind: nodeLiteral, char: char, flags: f}, nil
+}
+
+// escapeChar returns the character of an escape like \n or \x41.
+func (p *parser) escapeChar(c rune) (rune, error) {
+	switch c {
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'f':
+		return '\f', nil
+	case 'a':
+		return '\a', nil
+	case 'e':
+		return 0x1b, nil
+	case '0':
+		return 0, nil
+	case 'x':
+		var hex string
+		if !p.eof() && p.peek() == '{' {
+			end := strings.IndexRune(string(p.src[p.pos:]), '}')
+			if end < 0 {
+				return 0, fmt.Errorf(`missing } in \x{...}`)
+			}
+			hex = string(p.src[p.pos:])[1:end]
+			p.pos += len([]rune(string(p.src[p.pos:])[:end])) + 1
+		} else {
+			start := p.pos
+			for !p.eof() && p.pos-start < 2 && strings.ContainsRune("0123456789abcdefABCDEF", p.peek()) {
+				p.pos++
+			}
+			hex = string(p.src[start:p.pos])
+		}
+		if hex == "" {
+			return 0, nil
+		}
+		value, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return 0, fmt.Errorf(`invalid \x escape %q`, hex)
+		}
+		return rune(value), nil
+	}
+
+	// letters and digits have special meanings, everything else is literal
+	if unicode.IsLetter(c) || unicode.IsDigit(c) {
+		return 0, fmt.Errorf(`unsupported escape \%c`, c)
+	}
+	return c, nil
+}
+
+func (p *parser) parseClass() (*charClass, error) {
+	start := p.pos - 1
+	class := &charClass{}
+
+	if !p.eof() && p.peek() == '^' {
+		class.negate = true
+		p.pos++
+	}
+
+	first := true
+	for {
+		if p.eof() {
+			return nil, fmt.Errorf("missing terminating ] for character class at position %d", start)
+		}
+
+		c := p.peek()
+		if c == ']' && !first {
+			p.pos++
+			return class, nil
+		}
+		first = false
+
+		if c == '[' && p.lookingAt("[:") {
+			end := strings.Index(string(p.src[p.pos:]), ":]")
+			if end > 0 {
+				name := string(p.src[p.pos:])[2:end]
+				fn, ok := posixClasses[strings.TrimPrefix(name, "^")]
+				if !ok {
+					return nil, fmt.Errorf("unknown POSIX class name %q", name)
+				}
+				if strings.HasPrefix(name, "^") {
+					fn = negateFunc(fn)
+				}
+				class.funcs = append(class.funcs, fn)
+				p.pos += len([]rune(string(p.src[p.pos:])[:end])) + 2
+				continue
+			}
+		}
+
+		lo, isClass, err := p.classChar(class)
+		if err != nil {
+			return nil, err
+		}
+		if isClass {
+			continue
+		}
+
+		// a range like a-z, a "-" before the closing bracket is a literal
+		if p.lookingAt("-") && !p.lookingAt("-]") && p.pos+1 < len(p.src) {
+			p.pos++
+			hi, isClass, err := p.classChar(class)
+			if err != nil {
+				return nil, err
+			}
+			if isClass {
+				class.ranges = append(class.ranges, [2]rune{lo, lo}, [2]rune{'-', '-'})
+				continue
+			}
+			if hi < lo {
+				return nil, fmt.Errorf("range out of order in character class at position %d", p.pos)
+			}
+			class.ranges = append(class.ranges, [2]rune{lo, hi})
+			continue
+		}
+
+		class.ranges = append(class.ranges, [2]rune{lo, lo})
+	}
+}
+
+// classChar reads a character of a class. It reports whether it was a
+// class escape like \d, which is added to the class directly.
+func (p *parser) classChar(class *charClass) (rune, bool, error) {
+	c := p.peek()
+	p.pos++
+
+	if c != '\\' {
+		return c, false, nil
+	}
+
+	if p.eof() {
+		return 0, false, fmt.Errorf(`\ at end of pattern`)
+	}
+
+	c = p.peek()
+	p.pos++
+
+	if inner, ok := escapeClass(c); ok {
+		class.funcs = append(class.funcs, inner.matches)
+		return 0, true, nil
+	}
+
+	if c == 'b' {
+		return '\b', false, nil
+	}
+
+	char, err := p.escapeChar(c)
+	return char, false, err
+}
@@ -0,0 +1,527 @@
+package rewrite
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/internal/matcher"
+	"github.com/adityals/go-ngx-config/internal/pcre"
+	"github.com/adityals/go-ngx-config/internal/variable"
+)
+
+// nginx fails the request after this many uri changes in a location
+const maxCycles = 10
+
+type SimulateOptions struct {
+	// Request method, defaults to GET.
+	Method string
+
+	// Request headers, used for $http_* and $cookie_* variables.
+	Headers map[string]string
+
+	// Client address, used for $remote_addr and geo blocks. Defaults to
+	// 127.0.0.1.
+	RemoteAddr string
+
+	// Files that exist when evaluating -f, -d, -e and -x conditions and
+	// try_files.
+	FileSystem matcher.FileSystem
+}
+
+type Simulation struct {
+	Uri       string
+	Args      string
+	Status    int
+	Location  string
+	Body      string
+	Variables map[string]string
+	Match     *matcher.LocationMatcher
+	Steps     []Step
+	Cycles    int
+
+	// Locations the request went through by try_files and error_page.
+	Hops []matcher.LocationHop
+
+	// Uri the final location was matched with, rewrites inside of the
+	// location can change Uri after that.
+	MatchUri string
+
+	// Server and locations the request was matched against.
+	Scope *matcher.Scope
+
+	// Variables that couldn't be resolved, they are kept as is.
+	Unresolved []string
+
+	RegexErrors []matcher.RegexError
+
+	variables *variable.Evaluator
+
+	// the response is made without the final location handling the request
+	responded bool
+}
+
+// Expand resolves the variables of a directive arg as they are at the end of
+// the simulation, returning the ones that can't be resolved.
+func (sim *Simulation) Expand(value string) (string, []string) {
+	return sim.variables.Expand(value)
+}
+
+// Responded reports whether the response is made without the final location
+// handling the request, e.g. by return or a try_files status code.
+func (sim *Simulation) Responded() bool {
+	return sim.responded
+}
+
+// Step is a rewrite module directive that was run.
+type Step struct {
+	Directive string
+	Args      []string
+	Line      int
+	Uri       string
+	Result    string
+}
+
+type flow int
+
+const (
+	// go on with the next directive
+	flowNext flow = iota
+	// stop running rewrite directives and stay in the location
+	flowBreak
+	// stop running rewrite directives and search a location for the uri
+	flowLast
+	// a response was made by return or a redirect
+	flowStop
+)
+
+type simulator struct {
+	scope     *matcher.Scope
+	options   *SimulateOptions
+	result    *Simulation
+	variables *variable.Evaluator
+
+	// the uri was rewritten in the location, a location is searched again
+	// unless a break stops the rewrites
+	uriChanged bool
+}
+
+// Simulate runs the rewrite module directives of the server and the matched
+// location for the url, searching a location again after the uri is
+// rewritten in a location unless a break stops the rewrites. The internal
+// redirects of try_files and error_page are followed too.
+func Simulate(conf *crossplane.Payload, targetUrl string, opts *SimulateOptions) (*Simulation, error) {
+	if conf == nil {
+		return nil, crossplane.ErrNoConfig
+	}
+
+	if opts == nil {
+		opts = &SimulateOptions{}
+	}
+	if opts.FileSystem == nil {
+		opts.FileSystem = matcher.FileList{}
+	}
+
+	parsedUrl, err := url.Parse(targetUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, err := matcher.NewScope(conf, parsedUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	request := variable.NewRequest(parsedUrl, opts.Method, opts.Headers)
+	if opts.RemoteAddr != "" {
+		request.RemoteAddr = opts.RemoteAddr
+	}
+
+	sim := &simulator{
+		scope:   scope,
+		options: opts,
+		result: &Simulation{
+			Uri:        parsedUrl.Path,
+			Args:       parsedUrl.RawQuery,
+			Variables:  map[string]string{},
+			Steps:      []Step{},
+			Unresolved: []string{},
+		},
+		variables: variable.NewEvaluator(request),
+	}
+
+	for _, parent := range scope.Parents() {
+		if parent.Directive == "http" && parent.Block != nil {
+			sim.variables.Define(*parent.Block)
+		}
+	}
+
+	if server := scope.Server(); server != nil {
+		request.ServerName = server.PrimaryName()
+	}
+
+	// the server rewrites are run before searching a location, and again
+	// after an internal redirect to a uri
+	f, err := sim.runServer()
+	if err != nil {
+		return nil, err
+	}
+	if f == flowStop {
+		return sim.respond(), nil
+	}
+
+	redirects := matcher.NewRedirects(&matcher.MatchOptions{FileSystem: opts.FileSystem})
+	target := sim.result.Uri
+	for {
+		var match *matcher.LocationMatcher
+		if strings.HasPrefix(target, "@") {
+			match, err = scope.Named(target)
+		} else {
+			match, err = scope.Match(sim.result.Uri)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if match == nil {
+			sim.result.Status = 404
+			return sim.respond(), nil
+		}
+
+		sim.result.Match = match
+		sim.result.MatchUri = sim.result.Uri
+		request.DocumentRoot, request.Alias = scope.DocumentRoot(match)
+		request.LocationPath = match.MatchPath
+		if match.Captures != nil {
+			sim.setCaptures(match.Captures, match.NamedCaptures)
+		}
+
+		sim.uriChanged = false
+		f, err := sim.run(match.Directives.Block)
+		if err != nil {
+			return nil, err
+		}
+		if f == flowStop {
+			return sim.respond(), nil
+		}
+
+		if f == flowLast || (f == flowNext && sim.uriChanged) {
+			sim.result.Cycles++
+			if sim.result.Cycles > maxCycles {
+				sim.result.Status = 500
+				sim.result.Body = fmt.Sprintf("rewrite or internal redirection cycle while processing %q", sim.result.Uri)
+				return sim.respond(), nil
+			}
+			// a rewritten request is an internal one
+			redirects.Internal = true
+			target = sim.result.Uri
+			continue
+		}
+
+		// the location handles the request unless try_files or error_page
+		// redirect it
+		next, status := scope.Visit(redirects, match, target, sim.result.Uri, sim.result.Args)
+		sim.result.Hops = redirects.Hops
+		if next == "" {
+			if status != 0 {
+				sim.result.Status = status
+				return sim.respond(), nil
+			}
+			// the error page is sent with the status of the error
+			sim.result.Status = redirects.ErrorStatus()
+			return sim.done(), nil
+		}
+		if matcher.IsExternalRedirect(next) {
+			sim.result.Status = status
+			sim.result.Location = next
+			return sim.respond(), nil
+		}
+
+		target = next
+		if strings.HasPrefix(next, "@") {
+			continue
+		}
+
+		sim.setUri(matcher.SplitUri(next, sim.result.Args))
+		f, err = sim.runServer()
+		if err != nil {
+			return nil, err
+		}
+		if f == flowStop {
+			return sim.respond(), nil
+		}
+		target = sim.result.Uri
+	}
+}
+
+// runServer runs the rewrite module directives of the server block.
+func (s *simulator) runServer() (flow, error) {
+	server := s.scope.Server()
+	if server == nil {
+		return flowNext, nil
+	}
+
+	if server.Captures != nil {
+		s.setCaptures(server.Captures, server.NamedCaptures)
+	}
+	return s.run(server.Directives.Block)
+}
+
+// respond ends the simulation with a response made by a directive, the
+// final location doesn't handle the request.
+func (s *simulator) respond() *Simulation {
+	s.result.responded = true
+	return s.done()
+}
+
+func (s *simulator) done() *Simulation {
+	s.result.RegexErrors = s.scope.RegexErrors()
+	s.result.Variables = s.variables.Variables()
+	s.result.Scope = s.scope
+	s.result.variables = s.variables
+	return s.result
+}
+
+// run runs the rewrite module directives of a block in order.
+func (s *simulator) run(block *[]crossplane.Directive) (flow, error) {
+	for _, d := range *block {
+		var f flow
+		var err error
+
+		switch d.Directive {
+		case "rewrite":
+			f = s.rewrite(block, d)
+		case "return":
+			f = s.doReturn(d)
+		case "set":
+			f = s.set(d)
+		case "break":
+			s.step(d, "break")
+			s.uriChanged = false
+			f = flowBreak
+		case "if":
+			f, err = s.doIf(block, d)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return flowNext, err
+		}
+
+		if f != flowNext {
+			return f, nil
+		}
+	}
+
+	return flowNext, nil
+}
+
+func (s *simulator) step(d crossplane.Directive, result string) {
+	s.result.Steps = append(s.result.Steps, Step{
+		Directive: d.Directive,
+		Args:      d.Args,
+		Line:      d.Line,
+		Uri:       s.result.Uri,
+		Result:    result,
+	})
+}
+
+func (s *simulator) rewrite(block *[]crossplane.Directive, d crossplane.Directive) flow {
+	if len(d.Args) < 2 {
+		return flowNext
+	}
+
+	reg := s.scope.Compile(block, d.Line, d.Args[0], false)
+	if reg == nil {
+		s.step(d, "regex cannot be evaluated")
+		return flowNext
+	}
+
+	submatches, err := s.scope.Find(block, d.Line, reg, s.result.Uri)
+	if err != nil {
+		s.step(d, "regex cannot be evaluated")
+		return flowNext
+	}
+	if submatches == nil {
+		s.step(d, "no match")
+		return flowNext
+	}
+	s.setCaptures(pcre.Captures(reg, submatches))
+
+	flag := ""
+	if len(d.Args) > 2 {
+		flag = d.Args[2]
+	}
+
+	replacement := d.Args[1]
+	external := strings.HasPrefix(replacement, "http://") ||
+		strings.HasPrefix(replacement, "https://") ||
+		strings.HasPrefix(replacement, "$scheme")
+	target := s.expand(replacement)
+
+	// a replacement with args replaces the request args unless it ends with
+	// "?", in which case the request args are dropped
+	uri, args := target, s.result.Args
+	if idx := strings.Index(target, "?"); idx >= 0 {
+		uri = target[:idx]
+		newArgs := target[idx+1:]
+		if strings.HasSuffix(target, "?") {
+			args = strings.TrimSuffix(newArgs, "?")
+		} else if s.result.Args != "" {
+			args = newArgs + "&" + s.result.Args
+		} else {
+			args = newArgs
+		}
+	}
+
+	if external || flag == "redirect" || flag == "permanent" {
+		s.result.Status = 302
+		if flag == "permanent" {
+			s.result.Status = 301
+		}
+		s.result.Location = uri
+		if args != "" {
+			s.result.Location += "?" + args
+		}
+		s.step(d, fmt.Sprintf("redirect %d %s", s.result.Status, s.result.Location))
+		return flowStop
+	}
+
+	s.setUri(uri, args)
+
+	switch flag {
+	case "last":
+		s.step(d, "last "+uri)
+		return flowLast
+	case "break":
+		s.step(d, "break "+uri)
+		s.uriChanged = false
+		return flowBreak
+	}
+
+	s.step(d, "rewritten "+uri)
+	s.uriChanged = true
+	return flowNext
+}
+
+func (s *simulator) doReturn(d crossplane.Directive) flow {
+	if len(d.Args) == 0 {
+		return flowNext
+	}
+
+	code, err := strconv.Atoi(d.Args[0])
+
+	// "return URL" is a temporary redirect
+	if err != nil {
+		s.result.Status = 302
+		s.result.Location = s.expand(d.Args[0])
+		s.step(d, fmt.Sprintf("redirect %d %s", s.result.Status, s.result.Location))
+		return flowStop
+	}
+
+	s.result.Status = code
+	if len(d.Args) > 1 {
+		value := s.expand(d.Args[1])
+		if code == 301 || code == 302 || code == 303 || code == 307 || code == 308 {
+			s.result.Location = value
+		} else {
+			s.result.Body = value
+		}
+	}
+
+	s.step(d, fmt.Sprintf("return %d", code))
+	return flowStop
+}
+
+func (s *simulator) set(d crossplane.Directive) flow {
+	if len(d.Args) < 2 || !strings.HasPrefix(d.Args[0], "$") {
+		return flowNext
+	}
+
+	value := s.expand(d.Args[1])
+	s.variables.Set(d.Args[0][1:], value)
+	s.step(d, value)
+	return flowNext
+}
+
+func (s *simulator) doIf(block *[]crossplane.Directive, d crossplane.Directive) (flow, error) {
+	ok, err := s.condition(block, d)
+	if err != nil {
+		return flowNext, err
+	}
+
+	if !ok {
+		s.step(d, "false")
+		return flowNext, nil
+	}
+
+	s.step(d, "true")
+	if d.Block == nil {
+		return flowNext, nil
+	}
+	return s.run(d.Block)
+}
+
+// condition evaluates the args of an if directive found in the block.
+func (s *simulator) condition(block *[]crossplane.Directive, d crossplane.Directive) (bool, error) {
+	args := d.Args
+	switch len(args) {
+	case 1:
+		value := s.expand(args[0])
+		return value != "" && value != "0", nil
+	case 2:
+		return s.fileCondition(args[0], s.expand(args[1]))
+	case 3:
+		value := s.expand(args[0])
+		operand := args[2]
+
+		switch args[1] {
+		case "=":
+			return value == s.expand(operand), nil
+		case "!=":
+			return value != s.expand(operand), nil
+		case "~", "~*", "!~", "!~*":
+			// a regex that can't be evaluated never matches
+			reg := s.scope.Compile(block, d.Line, operand, strings.HasSuffix(args[1], "*"))
+			if reg == nil {
+				return false, nil
+			}
+
+			submatches, err := s.scope.Find(block, d.Line, reg, value)
+			if err != nil {
+				return false, nil
+			}
+			if strings.HasPrefix(args[1], "!") {
+				return submatches == nil, nil
+			}
+			if submatches != nil {
+				s.setCaptures(pcre.Captures(reg, submatches))
+			}
+			return submatches != nil, nil
+		}
+	}
+
+	return false, fmt.Errorf("invalid condition %q", strings.Join(args, " "))
+}
+
+func (s *simulator) fileCondition(operator string, name string) (bool, error) {
+	exists, isDir := s.options.FileSystem.Stat(name)
+
+	switch strings.TrimPrefix(operator, "!") {
+	case "-f", "-x":
+		exists = exists && !isDir
+	case "-d":
+		exists = exists && isDir
+	case "-e":
+	default:
+		return false, fmt.Errorf("invalid condition %q", operator)
+	}
+
+	if strings.HasPrefix(operator, "!") {
+		return !exists, nil
+	}
+	return exists, nil
+}
@@ -0,0 +1,27 @@
+package rewrite
+
+import "github.com/adityals/go-ngx-config/internal/crossplane"
+
+// expand resolves the variables of a directive arg, the ones that can't be
+// resolved are kept as is and reported in the simulation.
+func (s *simulator) expand(value string) string {
+	expanded, unresolved := s.variables.Expand(value)
+	for _, name := range unresolved {
+		if !crossplane.Contains(s.result.Unresolved, name) {
+			s.result.Unresolved = append(s.result.Unresolved, name)
+		}
+	}
+	return expanded
+}
+
+// setUri changes the uri and args of the request.
+func (s *simulator) setUri(uri string, args string) {
+	s.result.Uri, s.result.Args = uri, args
+	s.variables.Request.Uri, s.variables.Request.Args = uri, args
+}
+
+// setCaptures keeps the captures of the last regex match, named captures
+// are also set as variables like nginx does.
+func (s *simulator) setCaptures(captures []string, named map[string]string) {
+	s.variables.SetCaptures(captures, named)
+}
@@ -0,0 +1,28 @@
+package sarif
+
+import (
+	"github.com/adityals/go-ngx-config/internal/lint"
+)
+
+// AddFindings adds the findings of a lint run, describing the rules that
+// were run.
+func (l *Log) AddFindings(rules []lint.Rule, findings []lint.Finding) {
+	for _, rule := range rules {
+		l.AddRule(rule.ID(), rule.Description(), "")
+	}
+
+	for _, finding := range findings {
+		l.AddResult(finding.Rule, Level(finding.Severity), finding.Message, finding.File, finding.Line)
+	}
+}
+
+// Level returns the SARIF level of a severity.
+func Level(severity lint.Severity) string {
+	switch severity {
+	case lint.SeverityError:
+		return "error"
+	case lint.SeverityInfo:
+		return "note"
+	}
+	return "warning"
+}
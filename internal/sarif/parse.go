@@ -0,0 +1,90 @@
+package sarif
+
+import (
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+)
+
+// descriptions of the parse error kinds, every kind is described so that
+// the rules of a log don't depend on the errors found
+var parseErrorKinds = []struct {
+	kind        string
+	description string
+}{
+	{crossplane.KindParseError, "the config cannot be parsed"},
+	{crossplane.KindUnknownDirective, "the directive is not known to nginx"},
+	{crossplane.KindDirectiveNotAllowed, "the directive is not allowed in this context"},
+	{crossplane.KindMissingOpeningBrace, "the block directive has no opening brace"},
+	{crossplane.KindMissingSemicolon, "the directive is not terminated by a semicolon"},
+	{crossplane.KindInvalidFlag, "the value of the flag directive must be on or off"},
+	{crossplane.KindInvalidNumberOfArguments, "the directive has an invalid number of arguments"},
+	{crossplane.KindUnexpectedClosingBrace, "a closing brace has no block to close"},
+	{crossplane.KindUnexpectedEndOfFile, "the file ends before a block is closed"},
+	{crossplane.KindIncludeNotFound, "the included file cannot be opened"},
+	{crossplane.KindInvalidIncludeIndex, "an include refers to a config that is not in the payload"},
+	{crossplane.KindIncludeCycle, "a file includes itself, directly or through other files"},
+}
+
+// AddPayloadErrors adds the errors found while parsing a payload.
+func (l *Log) AddPayloadErrors(payload *crossplane.Payload) {
+	l.describeParseErrors()
+
+	for _, perr := range payload.Errors {
+		line := 0
+		if perr.Line != nil {
+			line = *perr.Line
+		}
+
+		kind := perr.Kind
+		if kind == "" {
+			kind = crossplane.KindParseError
+		}
+		l.AddResult(kind, "error", perr.Message(), perr.File, line)
+		l.setRange(perr.Range)
+	}
+}
+
+// AddError adds an error that stopped the parsing, like the ones returned
+// with StopParsingOnError.
+func (l *Log) AddError(err error) {
+	l.describeParseErrors()
+
+	file, line := "", 0
+	what := err.Error()
+	if perr, ok := err.(crossplane.ParseError); ok {
+		what = perr.What()
+		if perr.File() != nil {
+			file = *perr.File()
+		}
+		if perr.Line() != nil {
+			line = *perr.Line()
+		}
+	}
+
+	l.AddResult(crossplane.ErrorKind(err), "error", what, file, line)
+	if perr, ok := err.(crossplane.ParseError); ok {
+		l.setRange(perr.Range())
+	}
+}
+
+// setRange narrows the region of the last result to the range of the
+// directive or argument the error is about.
+func (l *Log) setRange(rng *crossplane.Range) {
+	results := l.Runs[0].Results
+	if rng == nil || len(results) == 0 || len(results[len(results)-1].Locations) == 0 {
+		return
+	}
+
+	location := &results[len(results)-1].Locations[0].PhysicalLocation
+	location.Region = &Region{
+		StartLine:   rng.Start.Line,
+		StartColumn: rng.Start.Column,
+		EndLine:     rng.End.Line,
+		EndColumn:   rng.End.Column,
+	}
+}
+
+func (l *Log) describeParseErrors() {
+	for _, kind := range parseErrorKinds {
+		l.AddRule(kind.kind, kind.description, "error")
+	}
+}
@@ -0,0 +1,161 @@
+package sarif
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+)
+
+const (
+	Schema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	Version = "2.1.0"
+
+	toolName = "go-ngx-config"
+	toolUri  = "https://github.com/adityals/go-ngx-config"
+)
+
+// Log is a SARIF 2.1.0 log with a single run of the tool.
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []Run  `json:"runs"`
+
+	rules map[string]int
+}
+
+type Run struct {
+	Tool       Tool     `json:"tool"`
+	Results    []Result `json:"results"`
+	ColumnKind string   `json:"columnKind,omitempty"`
+}
+
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+type Driver struct {
+	Name           string                `json:"name"`
+	Version        string                `json:"version,omitempty"`
+	InformationUri string                `json:"informationUri,omitempty"`
+	Rules          []ReportingDescriptor `json:"rules"`
+}
+
+// ReportingDescriptor describes a rule results refer to by id.
+type ReportingDescriptor struct {
+	ID                   string         `json:"id"`
+	ShortDescription     *Message       `json:"shortDescription,omitempty"`
+	DefaultConfiguration *Configuration `json:"defaultConfiguration,omitempty"`
+}
+
+type Configuration struct {
+	Level string `json:"level"`
+}
+
+type Message struct {
+	Text string `json:"text"`
+}
+
+type Result struct {
+	RuleID    string     `json:"ruleId"`
+	RuleIndex int        `json:"ruleIndex"`
+	Level     string     `json:"level"`
+	Message   Message    `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+}
+
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type Region struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// NewLog returns an empty log for the given version of the tool.
+func NewLog(version string) *Log {
+	return &Log{
+		Schema:  Schema,
+		Version: Version,
+		Runs: []Run{{
+			Tool: Tool{Driver: Driver{
+				Name:           toolName,
+				Version:        version,
+				InformationUri: toolUri,
+				Rules:          []ReportingDescriptor{},
+			}},
+			Results: []Result{},
+			// the parser counts columns in runes
+			ColumnKind: "unicodeCodePoints",
+		}},
+		rules: map[string]int{},
+	}
+}
+
+// AddRule describes a rule, adding it again only updates its description.
+func (l *Log) AddRule(id string, description string, level string) int {
+	driver := &l.Runs[0].Tool.Driver
+
+	descriptor := ReportingDescriptor{ID: id}
+	if description != "" {
+		descriptor.ShortDescription = &Message{Text: description}
+	}
+	if level != "" {
+		descriptor.DefaultConfiguration = &Configuration{Level: level}
+	}
+
+	if idx, ok := l.rules[id]; ok {
+		driver.Rules[idx] = descriptor
+		return idx
+	}
+
+	l.rules[id] = len(driver.Rules)
+	driver.Rules = append(driver.Rules, descriptor)
+	return l.rules[id]
+}
+
+// AddResult adds a result of a rule found in a file, a line of 0 means the
+// whole file. Rules that weren't described are added with their id only.
+func (l *Log) AddResult(ruleId string, level string, message string, file string, line int) {
+	idx, ok := l.rules[ruleId]
+	if !ok {
+		idx = l.AddRule(ruleId, "", "")
+	}
+
+	result := Result{
+		RuleID:    ruleId,
+		RuleIndex: idx,
+		Level:     level,
+		Message:   Message{Text: message},
+	}
+
+	if file != "" {
+		location := Location{PhysicalLocation: PhysicalLocation{
+			ArtifactLocation: ArtifactLocation{URI: filepath.ToSlash(file)},
+		}}
+		if line > 0 {
+			location.PhysicalLocation.Region = &Region{StartLine: line}
+		}
+		result.Locations = []Location{location}
+	}
+
+	l.Runs[0].Results = append(l.Runs[0].Results, result)
+}
+
+// Write writes the log as indented JSON.
+func (l *Log) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(l)
+}
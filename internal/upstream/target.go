@@ -0,0 +1,179 @@
+package upstream
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/internal/matcher"
+	"github.com/adityals/go-ngx-config/internal/rewrite"
+)
+
+// directives that hand the request over to another server
+var passDirectives = []string{"proxy_pass", "grpc_pass", "fastcgi_pass", "uwsgi_pass", "memcached_pass"}
+
+// Target is where a location sends the request to.
+type Target struct {
+	Directive string
+	Line      int
+
+	// Arg of the directive with its variables expanded.
+	Pass    string
+	Scheme  string
+	Address string
+
+	// Upstream block of the address, nil if it's a host or a socket.
+	Upstream *Upstream
+
+	// Uri sent upstream by proxy_pass and grpc_pass, with its args.
+	Uri string
+
+	// Why the uri can't be computed, like nginx refusing the config.
+	UriError string
+
+	Location   *matcher.LocationMatcher
+	Unresolved []string
+}
+
+// Resolve simulates the request and finds where the location handling it,
+// after the rewrites and internal redirects, sends it to. The target is nil
+// if the request gets a response without being passed, e.g. by return.
+func Resolve(conf *crossplane.Payload, targetUrl string, opts *rewrite.SimulateOptions) (*Target, error) {
+	sim, err := rewrite.Simulate(conf, targetUrl, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return ResolveSimulation(sim), nil
+}
+
+// ResolveSimulation finds where the location a simulation ended in sends
+// the request to.
+func ResolveSimulation(sim *rewrite.Simulation) *Target {
+	if sim.Responded() || sim.Match == nil || sim.Match.Directives.Block == nil {
+		return nil
+	}
+
+	var pass *crossplane.Directive
+	for i, d := range *sim.Match.Directives.Block {
+		if crossplane.Contains(passDirectives, d.Directive) && len(d.Args) > 0 {
+			pass = &(*sim.Match.Directives.Block)[i]
+			break
+		}
+	}
+	if pass == nil {
+		return nil
+	}
+
+	expanded, unresolved := sim.Expand(pass.Args[0])
+	scheme, address, uri, hasUri := splitPass(pass.Directive, expanded)
+
+	target := &Target{
+		Directive:  pass.Directive,
+		Line:       pass.Line,
+		Pass:       expanded,
+		Scheme:     scheme,
+		Address:    address,
+		Upstream:   find(Upstreams(sim.Scope), address),
+		Location:   sim.Match,
+		Unresolved: unresolved,
+	}
+
+	switch pass.Directive {
+	case "proxy_pass":
+		withVariables := strings.Contains(pass.Args[0], "$")
+		proxied, err := proxyUri(sim, uri, hasUri, withVariables)
+		if err != nil {
+			target.UriError = err.Error()
+		}
+		target.Uri = proxied
+	case "grpc_pass":
+		target.Uri = requestUri(sim)
+	}
+
+	return target
+}
+
+// splitPass splits the arg of a pass directive into its scheme, address and
+// uri part.
+func splitPass(directive string, pass string) (string, string, string, bool) {
+	scheme := ""
+	if idx := strings.Index(pass, "://"); idx >= 0 {
+		scheme, pass = pass[:idx], pass[idx+3:]
+	}
+
+	// only proxy_pass can have a uri part
+	if directive != "proxy_pass" {
+		return scheme, pass, "", false
+	}
+
+	// a unix socket path ends with a colon when a uri follows it
+	if strings.HasPrefix(pass, "unix:") {
+		if idx := strings.Index(pass[len("unix:"):], ":"); idx >= 0 {
+			end := len("unix:") + idx
+			return scheme, pass[:end], pass[end+1:], true
+		}
+		return scheme, pass, "", false
+	}
+
+	if idx := strings.Index(pass, "/"); idx >= 0 {
+		return scheme, pass[:idx], pass[idx:], true
+	}
+	return scheme, pass, "", false
+}
+
+// proxyUri follows the proxy_pass rules for the uri sent upstream:
+//   - without a uri part, the request uri is passed as it was received, or
+//     the changed uri if it was changed
+//   - with a uri part, the part of the uri matching the location is
+//     replaced by it, unless the uri was changed by a rewrite inside of the
+//     location, in which case the changed uri is passed
+//   - with variables, a uri part is passed as is
+func proxyUri(sim *rewrite.Simulation, uri string, hasUri bool, withVariables bool) (string, error) {
+	if !hasUri {
+		return requestUri(sim), nil
+	}
+
+	if withVariables {
+		return uri, nil
+	}
+
+	if sim.Uri != sim.MatchUri {
+		return changedUri(sim), nil
+	}
+
+	match := sim.Match
+	if match.MatchModifer == matcher.REGEX || match.MatchModifer == matcher.REGEX_NO_CASE_SENSITIVE || strings.HasPrefix(match.MatchPath, "@") {
+		return "", errors.New(`"proxy_pass" cannot have URI part in location given by regular expression, or inside named location`)
+	}
+
+	rest := strings.TrimPrefix(sim.Uri, match.MatchPath)
+	return withArgs(uri+escape(rest), sim.Args), nil
+}
+
+// requestUri returns the request uri as it was received if the uri and args
+// didn't change, otherwise the changed ones.
+func requestUri(sim *rewrite.Simulation) string {
+	raw, _ := sim.Expand("$request_uri")
+	original, err := url.ParseRequestURI(raw)
+	if err == nil && original.Path == sim.Uri && original.RawQuery == sim.Args {
+		return raw
+	}
+	return changedUri(sim)
+}
+
+func changedUri(sim *rewrite.Simulation) string {
+	return withArgs(escape(sim.Uri), sim.Args)
+}
+
+func escape(path string) string {
+	return (&url.URL{Path: path}).EscapedPath()
+}
+
+func withArgs(uri string, args string) string {
+	if args == "" {
+		return uri
+	}
+	return uri + "?" + args
+}
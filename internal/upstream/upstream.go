@@ -0,0 +1,138 @@
+package upstream
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/internal/matcher"
+)
+
+// Upstream is an upstream block of the http context.
+type Upstream struct {
+	Name string
+
+	// Balancing method: round_robin, ip_hash, least_conn, hash, random or
+	// least_time.
+	Method string
+
+	// Key of the hash method, and whether it uses consistent hashing.
+	Key        string
+	Consistent bool
+
+	Servers    []Server
+	Directives crossplane.Directive
+	File       string
+}
+
+// Server is a server of an upstream block with its parameters, defaulted
+// like nginx does.
+type Server struct {
+	Address     string
+	Weight      int
+	MaxFails    int
+	FailTimeout string
+	MaxConns    int
+	Backup      bool
+	Down        bool
+	Line        int
+}
+
+// Upstreams returns the upstream blocks of the http block the request was
+// matched in.
+func Upstreams(scope *matcher.Scope) []Upstream {
+	upstreams := []Upstream{}
+
+	for _, parent := range scope.Parents() {
+		if parent.Directive != "http" || parent.Block == nil {
+			continue
+		}
+
+		for _, d := range *parent.Block {
+			if d.Directive == "upstream" && len(d.Args) == 1 && d.Block != nil {
+				upstream := newUpstream(d)
+				upstream.File = scope.File(d.Block)
+				upstreams = append(upstreams, upstream)
+			}
+		}
+	}
+
+	return upstreams
+}
+
+func newUpstream(d crossplane.Directive) Upstream {
+	upstream := Upstream{
+		Name:       d.Args[0],
+		Method:     "round_robin",
+		Servers:    []Server{},
+		Directives: d,
+	}
+
+	for _, stmt := range *d.Block {
+		switch stmt.Directive {
+		case "server":
+			if len(stmt.Args) > 0 {
+				upstream.Servers = append(upstream.Servers, newServer(stmt))
+			}
+		case "ip_hash", "least_conn", "least_time", "random":
+			upstream.Method = stmt.Directive
+		case "hash":
+			upstream.Method = "hash"
+			if len(stmt.Args) > 0 {
+				upstream.Key = stmt.Args[0]
+			}
+			upstream.Consistent = len(stmt.Args) > 1 && stmt.Args[1] == "consistent"
+		}
+	}
+
+	return upstream
+}
+
+func newServer(d crossplane.Directive) Server {
+	server := Server{
+		Address:     d.Args[0],
+		Weight:      1,
+		MaxFails:    1,
+		FailTimeout: "10s",
+		Line:        d.Line,
+	}
+
+	for _, arg := range d.Args[1:] {
+		name, value := arg, ""
+		if idx := strings.Index(arg, "="); idx >= 0 {
+			name, value = arg[:idx], arg[idx+1:]
+		}
+
+		switch name {
+		case "weight":
+			server.Weight, _ = strconv.Atoi(value)
+		case "max_fails":
+			server.MaxFails, _ = strconv.Atoi(value)
+		case "fail_timeout":
+			server.FailTimeout = value
+		case "max_conns":
+			server.MaxConns, _ = strconv.Atoi(value)
+		case "backup":
+			server.Backup = true
+		case "down":
+			server.Down = true
+		}
+	}
+
+	return server
+}
+
+// find returns the upstream block a pass address refers to. Like nginx, an
+// upstream block is only used when the address has no port.
+func find(upstreams []Upstream, address string) *Upstream {
+	if strings.HasPrefix(address, "unix:") || strings.Contains(address, ":") {
+		return nil
+	}
+
+	for i, upstream := range upstreams {
+		if strings.EqualFold(upstream.Name, address) {
+			return &upstreams[i]
+		}
+	}
+	return nil
+}
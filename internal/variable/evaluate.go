@@ -0,0 +1,89 @@
+package variable
+
+import (
+	"net/url"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/internal/matcher"
+)
+
+type Options struct {
+	// Request method, defaults to GET.
+	Method string
+
+	// Request headers, used for $http_* and $cookie_* variables.
+	Headers map[string]string
+
+	// Client address, defaults to 127.0.0.1.
+	RemoteAddr string
+}
+
+// ForRequest returns an evaluator for the location handling the url. The map
+// and geo blocks of the http block are defined, the captures of the server
+// name and locations are set, then the set directives of the server and the
+// matched locations are applied in order.
+func ForRequest(conf *crossplane.Payload, targetUrl string, opts *Options) (*Evaluator, error) {
+	if conf == nil {
+		return nil, crossplane.ErrNoConfig
+	}
+
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	parsedUrl, err := url.Parse(targetUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, err := matcher.NewScope(conf, parsedUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	request := NewRequest(parsedUrl, opts.Method, opts.Headers)
+	if opts.RemoteAddr != "" {
+		request.RemoteAddr = opts.RemoteAddr
+	}
+
+	e := NewEvaluator(request)
+
+	parents := scope.Parents()
+	for i := len(parents) - 1; i >= 0; i-- {
+		if parents[i].Directive == "http" && parents[i].Block != nil {
+			e.Define(*parents[i].Block)
+		}
+	}
+
+	server := scope.Server()
+	if server != nil {
+		request.ServerName = server.PrimaryName()
+		if server.Captures != nil {
+			e.SetCaptures(server.Captures, server.NamedCaptures)
+		}
+		e.ApplySets(*server.Directives.Block)
+	}
+
+	match, err := scope.Match(request.Uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if match == nil {
+		return e, nil
+	}
+
+	request.DocumentRoot, request.Alias = scope.DocumentRoot(match)
+	request.LocationPath = match.MatchPath
+
+	if match.Captures != nil {
+		e.SetCaptures(match.Captures, match.NamedCaptures)
+	}
+	for _, location := range match.Chain {
+		if location.Directives.Block != nil {
+			e.ApplySets(*location.Directives.Block)
+		}
+	}
+
+	return e, nil
+}
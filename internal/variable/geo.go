@@ -0,0 +1,131 @@
+package variable
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+)
+
+// Geo is a "geo [$address] $variable" block.
+type Geo struct {
+	// Address the value depends on, $remote_addr if empty.
+	Source   string
+	Variable string
+	Default  string
+
+	networks []geoNetwork
+	ranges   []geoRange
+}
+
+type geoNetwork struct {
+	network *net.IPNet
+	value   string
+}
+
+type geoRange struct {
+	from  net.IP
+	to    net.IP
+	value string
+}
+
+// NewGeo reads a geo directive.
+func NewGeo(d crossplane.Directive) (*Geo, error) {
+	if d.Directive != "geo" || d.Block == nil || len(d.Args) == 0 || len(d.Args) > 2 {
+		return nil, errors.New("invalid geo directive")
+	}
+
+	g := &Geo{Variable: d.Args[len(d.Args)-1]}
+	if len(d.Args) == 2 {
+		g.Source = d.Args[0]
+	}
+	if !strings.HasPrefix(g.Variable, "$") {
+		return nil, fmt.Errorf("invalid geo variable %q", g.Variable)
+	}
+	g.Variable = g.Variable[1:]
+
+	ranges := false
+	for _, entry := range *d.Block {
+		if entry.IsComment() {
+			continue
+		}
+
+		if len(entry.Args) == 0 {
+			if entry.Directive == "ranges" {
+				ranges = true
+			}
+			continue
+		}
+
+		key, value := entry.Directive, entry.Args[0]
+		switch key {
+		case "default":
+			g.Default = value
+			continue
+		case "proxy", "proxy_recursive", "delete", "include":
+			continue
+		}
+
+		if ranges {
+			if bounds := strings.SplitN(key, "-", 2); len(bounds) == 2 {
+				from, to := net.ParseIP(bounds[0]), net.ParseIP(bounds[1])
+				if from != nil && to != nil {
+					g.ranges = append(g.ranges, geoRange{from: from.To16(), to: to.To16(), value: value})
+				}
+			}
+			continue
+		}
+
+		if network := parseNetwork(key); network != nil {
+			g.networks = append(g.networks, geoNetwork{network: network, value: value})
+		}
+	}
+
+	return g, nil
+}
+
+// parseNetwork reads a CIDR or a single address.
+func parseNetwork(s string) *net.IPNet {
+	if _, network, err := net.ParseCIDR(s); err == nil {
+		return network
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// Evaluate returns the value for an address, the longest matching network
+// wins like in nginx.
+func (g *Geo) Evaluate(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return g.Default
+	}
+
+	for _, r := range g.ranges {
+		if bytes.Compare(ip.To16(), r.from) >= 0 && bytes.Compare(ip.To16(), r.to) <= 0 {
+			return r.value
+		}
+	}
+
+	value, longest := g.Default, -1
+	for _, n := range g.networks {
+		if !n.network.Contains(ip) {
+			continue
+		}
+		if ones, _ := n.network.Mask.Size(); ones > longest {
+			value, longest = n.value, ones
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,180 @@
+package variable
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/internal/matcher"
+	"github.com/adityals/go-ngx-config/internal/pcre"
+)
+
+// Map is a "map $source $variable" block.
+type Map struct {
+	Source   string
+	Variable string
+	Default  string
+
+	// With hostnames, keys can have a leading or trailing wildcard like
+	// server names.
+	Hostnames bool
+
+	// A volatile map is evaluated on every use, otherwise its value is kept
+	// for the rest of the request.
+	Volatile bool
+
+	// Regex keys that can't be evaluated, they never match.
+	RegexErrors []error
+
+	exact     map[string]mapEntry
+	wildcards []mapEntry
+	regexes   []mapEntry
+}
+
+type mapEntry struct {
+	key   string
+	value string
+	reg   *pcre.Regexp
+}
+
+// MapMatch is the outcome of evaluating a map for a source value.
+type MapMatch struct {
+	// Key that matched, empty if the default value is used.
+	Key     string
+	Value   string
+	Default bool
+
+	// Captures of a regex key, which its value can use.
+	Captures      []string
+	NamedCaptures map[string]string
+}
+
+// NewMap reads a map directive.
+func NewMap(d crossplane.Directive) (*Map, error) {
+	if d.Directive != "map" || len(d.Args) != 2 || d.Block == nil {
+		return nil, errors.New("invalid map directive")
+	}
+
+	if !strings.HasPrefix(d.Args[1], "$") {
+		return nil, fmt.Errorf("invalid map variable %q", d.Args[1])
+	}
+
+	m := &Map{
+		Source:   d.Args[0],
+		Variable: d.Args[1][1:],
+		exact:    map[string]mapEntry{},
+	}
+
+	// hostnames changes how every key is read, wherever it is in the block
+	for _, entry := range *d.Block {
+		if entry.Directive == "hostnames" && len(entry.Args) == 0 {
+			m.Hostnames = true
+		}
+	}
+
+	for _, entry := range *d.Block {
+		if entry.IsComment() {
+			continue
+		}
+
+		key := entry.Directive
+		if len(entry.Args) == 0 {
+			if key == "volatile" {
+				m.Volatile = true
+			}
+			continue
+		}
+		if len(entry.Args) != 1 {
+			continue
+		}
+		value := entry.Args[0]
+
+		switch {
+		case key == "default":
+			m.Default = value
+		case strings.HasPrefix(key, "~"):
+			caseless := strings.HasPrefix(key, "~*")
+			expr := strings.TrimPrefix(strings.TrimPrefix(key, "~*"), "~")
+
+			reg, err := pcre.Compile(expr, caseless)
+			if err != nil {
+				m.RegexErrors = append(m.RegexErrors, err)
+				continue
+			}
+			m.regexes = append(m.regexes, mapEntry{key: key, value: value, reg: reg})
+		case m.Hostnames && isWildcard(key):
+			m.wildcards = append(m.wildcards, mapEntry{key: strings.ToLower(key), value: value})
+		default:
+			// a leading backslash escapes keys like "default"
+			key = strings.ToLower(strings.TrimPrefix(key, "\\"))
+			if _, ok := m.exact[key]; !ok {
+				m.exact[key] = mapEntry{key: key, value: value}
+			}
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Map) addRegexError(err error) {
+	for _, known := range m.RegexErrors {
+		if known.Error() == err.Error() {
+			return
+		}
+	}
+	m.RegexErrors = append(m.RegexErrors, err)
+}
+
+func isWildcard(key string) bool {
+	return strings.HasPrefix(key, "*.") || strings.HasPrefix(key, ".") || strings.HasSuffix(key, ".*")
+}
+
+// Evaluate finds the value for a source value like nginx does: an exact key
+// first, then the longest key with a leading wildcard, the longest key with
+// a trailing wildcard, the first matching regex and the default value. The
+// value is returned as is, without expanding its variables.
+func (m *Map) Evaluate(source string) MapMatch {
+	// string keys are case insensitive, and with hostnames the trailing dot
+	// of a host is ignored
+	if m.Hostnames {
+		source = strings.TrimSuffix(source, ".")
+	}
+	lower := strings.ToLower(source)
+
+	if entry, ok := m.exact[lower]; ok {
+		return MapMatch{Key: entry.key, Value: entry.value}
+	}
+
+	if best, ok := m.longestWildcard(lower, matcher.MatchLeadingWildcard); ok {
+		return MapMatch{Key: best.key, Value: best.value}
+	}
+	if best, ok := m.longestWildcard(lower, matcher.MatchTrailingWildcard); ok {
+		return MapMatch{Key: best.key, Value: best.value}
+	}
+
+	for _, entry := range m.regexes {
+		submatches, err := entry.reg.FindStringSubmatch(source)
+		if err != nil {
+			m.addRegexError(err)
+			continue
+		}
+		if submatches != nil {
+			captures, named := pcre.Captures(entry.reg, submatches)
+			return MapMatch{Key: entry.key, Value: entry.value, Captures: captures, NamedCaptures: named}
+		}
+	}
+
+	return MapMatch{Value: m.Default, Default: true}
+}
+
+func (m *Map) longestWildcard(source string, match func(string, string) bool) (mapEntry, bool) {
+	var best mapEntry
+	found := false
+	for _, entry := range m.wildcards {
+		if match(entry.key, source) && len(entry.key) > len(best.key) {
+			best, found = entry, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,150 @@
+package variable
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Request is the simulated request variables are resolved against.
+type Request struct {
+	Url     *url.URL
+	Method  string
+	Headers map[string]string
+
+	// Client address, used for $remote_addr and geo blocks. Defaults to
+	// 127.0.0.1.
+	RemoteAddr string
+
+	// Current uri and args, changed by rewrites.
+	Uri  string
+	Args string
+
+	// First name of the server block handling the request.
+	ServerName string
+
+	// Root or alias of the location handling the request, used for
+	// $document_root and $request_filename. An alias replaces the
+	// LocationPath part of the uri.
+	DocumentRoot string
+	Alias        string
+	LocationPath string
+}
+
+func NewRequest(u *url.URL, method string, headers map[string]string) *Request {
+	return &Request{
+		Url:        u,
+		Method:     method,
+		Headers:    headers,
+		RemoteAddr: "127.0.0.1",
+		Uri:        u.Path,
+		Args:       u.RawQuery,
+	}
+}
+
+// lookup returns the value of a builtin variable of the request.
+func (r *Request) lookup(name string) (string, bool) {
+	switch name {
+	case "uri", "document_uri":
+		return r.Uri, true
+	case "args", "query_string":
+		return r.Args, true
+	case "is_args":
+		if r.Args != "" {
+			return "?", true
+		}
+		return "", true
+	case "request_uri":
+		return r.Url.RequestURI(), true
+	case "request":
+		return r.method() + " " + r.Url.RequestURI() + " HTTP/1.1", true
+	case "scheme":
+		return r.scheme(), true
+	case "host":
+		if host := strings.ToLower(r.Url.Hostname()); host != "" {
+			return host, true
+		}
+		return r.ServerName, true
+	case "server_name":
+		return r.ServerName, true
+	case "server_port":
+		if port := r.Url.Port(); port != "" {
+			return port, true
+		}
+		if r.scheme() == "https" {
+			return "443", true
+		}
+		return "80", true
+	case "server_protocol":
+		return "HTTP/1.1", true
+	case "request_method":
+		return r.method(), true
+	case "https":
+		if r.scheme() == "https" {
+			return "on", true
+		}
+		return "", true
+	case "remote_addr":
+		return r.RemoteAddr, true
+	case "document_root":
+		if r.Alias != "" {
+			return r.Alias, true
+		}
+		return r.DocumentRoot, true
+	case "request_filename":
+		if r.Alias != "" {
+			return r.Alias + strings.TrimPrefix(r.Uri, r.LocationPath), true
+		}
+		return r.DocumentRoot + r.Uri, true
+	case "content_type":
+		return r.header("content-type"), true
+	case "content_length":
+		return r.header("content-length"), true
+	}
+
+	if strings.HasPrefix(name, "arg_") {
+		values, _ := url.ParseQuery(r.Args)
+		return values.Get(name[len("arg_"):]), true
+	}
+
+	if strings.HasPrefix(name, "http_") {
+		header := strings.ReplaceAll(name[len("http_"):], "_", "-")
+		if header == "host" && r.Url.Host != "" {
+			return r.Url.Host, true
+		}
+		return r.header(header), true
+	}
+
+	if strings.HasPrefix(name, "cookie_") {
+		req := http.Request{Header: http.Header{"Cookie": {r.header("cookie")}}}
+		if cookie, err := req.Cookie(name[len("cookie_"):]); err == nil {
+			return cookie.Value, true
+		}
+		return "", true
+	}
+
+	return "", false
+}
+
+func (r *Request) scheme() string {
+	if r.Url.Scheme == "" {
+		return "http"
+	}
+	return r.Url.Scheme
+}
+
+func (r *Request) method() string {
+	if r.Method == "" {
+		return "GET"
+	}
+	return r.Method
+}
+
+func (r *Request) header(name string) string {
+	for key, value := range r.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,236 @@
+package variable
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+)
+
+// nested map values referencing other maps are only expanded this deep
+const maxDepth = 10
+
+// Evaluator resolves the nginx variables of directive args for a simulated
+// request, using the set, map and geo directives of the config.
+type Evaluator struct {
+	Request *Request
+
+	variables map[string]string
+	captures  []string
+	maps      map[string]*Map
+	geos      map[string]*Geo
+
+	// values of the maps that aren't volatile
+	cached map[string]string
+}
+
+func NewEvaluator(request *Request) *Evaluator {
+	return &Evaluator{
+		Request:   request,
+		variables: map[string]string{},
+		maps:      map[string]*Map{},
+		geos:      map[string]*Geo{},
+		cached:    map[string]string{},
+	}
+}
+
+// Define adds the map and geo blocks found directly in block, like the ones
+// of an http block.
+func (e *Evaluator) Define(block []crossplane.Directive) {
+	for _, d := range block {
+		switch d.Directive {
+		case "map":
+			if m, err := NewMap(d); err == nil {
+				e.maps[m.Variable] = m
+			}
+		case "geo":
+			if g, err := NewGeo(d); err == nil {
+				e.geos[g.Variable] = g
+			}
+		}
+	}
+}
+
+// ApplySets runs the set directives found directly in block in order, the
+// ones inside of if blocks are skipped since they depend on the condition.
+func (e *Evaluator) ApplySets(block []crossplane.Directive) {
+	for _, d := range block {
+		if d.Directive == "set" && len(d.Args) == 2 && strings.HasPrefix(d.Args[0], "$") {
+			value, _ := e.Expand(d.Args[1])
+			e.Set(d.Args[0][1:], value)
+		}
+	}
+}
+
+// Map returns the map block defining a variable, or nil.
+func (e *Evaluator) Map(name string) *Map {
+	return e.maps[name]
+}
+
+// Set sets a variable like the set directive does.
+func (e *Evaluator) Set(name string, value string) {
+	e.variables[name] = value
+}
+
+// SetCaptures keeps the captures of the last regex match for $1..$9, named
+// captures are set as variables like nginx does.
+func (e *Evaluator) SetCaptures(captures []string, named map[string]string) {
+	e.captures = captures
+	for name, value := range named {
+		e.variables[name] = value
+	}
+}
+
+// Variables returns the variables set so far.
+func (e *Evaluator) Variables() map[string]string {
+	variables := map[string]string{}
+	for name, value := range e.variables {
+		variables[name] = value
+	}
+	return variables
+}
+
+// Expand replaces the $name, ${name} and $1..$9 variables in value. It also
+// returns the names of the variables it couldn't resolve, which are kept
+// as is in the value.
+func (e *Evaluator) Expand(value string) (string, []string) {
+	unresolved := map[string]bool{}
+	expanded := e.expand(value, 0, unresolved)
+
+	names := make([]string, 0, len(unresolved))
+	for name := range unresolved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return expanded, names
+}
+
+func (e *Evaluator) expand(value string, depth int, unresolved map[string]bool) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' || i == len(value)-1 {
+			sb.WriteByte(value[i])
+			continue
+		}
+
+		// a single digit is a regex capture
+		if c := value[i+1]; c >= '0' && c <= '9' {
+			idx := int(c - '0')
+			if idx < len(e.captures) {
+				sb.WriteString(e.captures[idx])
+			}
+			i++
+			continue
+		}
+
+		name, length := variableName(value[i+1:])
+		if length == 0 {
+			sb.WriteByte(value[i])
+			continue
+		}
+
+		resolved, ok := e.lookup(name, depth, unresolved)
+		if !ok {
+			unresolved[name] = true
+			sb.WriteString(value[i : i+1+length])
+		} else {
+			sb.WriteString(resolved)
+		}
+		i += length
+	}
+
+	return sb.String()
+}
+
+// Lookup returns the value of a variable and whether it could be resolved.
+func (e *Evaluator) Lookup(name string) (string, bool) {
+	return e.lookup(name, 0, map[string]bool{})
+}
+
+func (e *Evaluator) lookup(name string, depth int, unresolved map[string]bool) (string, bool) {
+	if value, ok := e.variables[name]; ok {
+		return value, true
+	}
+
+	if depth < maxDepth {
+		if m, ok := e.maps[name]; ok {
+			if value, ok := e.cached[name]; ok && !m.Volatile {
+				return value, true
+			}
+
+			source := e.expand(m.Source, depth+1, unresolved)
+			match := m.Evaluate(source)
+
+			// the value of a regex key can use its captures
+			saved := e.captures
+			if match.Captures != nil {
+				e.captures = match.Captures
+			}
+			for key, capture := range match.NamedCaptures {
+				if _, ok := e.variables[key]; !ok {
+					e.variables[key] = capture
+				}
+			}
+			value := e.expand(match.Value, depth+1, unresolved)
+			e.captures = saved
+
+			e.cached[name] = value
+			return value, true
+		}
+
+		if g, ok := e.geos[name]; ok {
+			source := e.Request.RemoteAddr
+			if g.Source != "" {
+				source = e.expand(g.Source, depth+1, unresolved)
+			}
+			return g.Evaluate(source), true
+		}
+	}
+
+	if idx := captureIndex(name); idx >= 0 {
+		if idx < len(e.captures) {
+			return e.captures[idx], true
+		}
+		return "", true
+	}
+
+	return e.Request.lookup(name)
+}
+
+func captureIndex(name string) int {
+	if name == "" {
+		return -1
+	}
+	idx := 0
+	for _, c := range name {
+		if c < '0' || c > '9' {
+			return -1
+		}
+		idx = idx*10 + int(c-'0')
+	}
+	return idx
+}
+
+// variableName reads a variable name after a "$", returning the name and
+// how many bytes it used.
+func variableName(s string) (string, int) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.Index(s, "}")
+		if end < 0 {
+			return "", 0
+		}
+		return s[1:end], end + 1
+	}
+
+	end := 0
+	for end < len(s) && isNameChar(s[end]) {
+		end++
+	}
+	return s[:end], end
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
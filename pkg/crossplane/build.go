@@ -0,0 +1,32 @@
+package crossplane
+
+import (
+	"io"
+
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+)
+
+// BuildOptions determine how a config is rendered back to nginx syntax.
+type BuildOptions = crossplane.BuildOptions
+
+// Build writes the given directives as nginx syntax.
+func Build(w io.Writer, block []Directive, options *BuildOptions) error {
+	return crossplane.Build(w, block, options)
+}
+
+// BuildConfig writes a parsed config file back as nginx syntax.
+func BuildConfig(w io.Writer, config Config, options *BuildOptions) error {
+	return crossplane.BuildConfig(w, config, options)
+}
+
+// BuildString renders the given directives as nginx syntax.
+func BuildString(block []Directive, options *BuildOptions) (string, error) {
+	return crossplane.BuildString(block, options)
+}
+
+// BuildFiles writes every config of an uncombined payload back to its file,
+// keeping include directives as is. If dir is not empty, the files are
+// written under dir keeping their layout relative to the main config.
+func BuildFiles(payload Payload, dir string, options *BuildOptions) error {
+	return crossplane.BuildFiles(payload, dir, options)
+}
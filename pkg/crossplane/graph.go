@@ -0,0 +1,16 @@
+package crossplane
+
+import "github.com/adityals/go-ngx-config/internal/crossplane"
+
+// IncludeGraph tells which file includes which of a payload.
+type IncludeGraph = crossplane.IncludeGraph
+
+// IncludeEdge is an include directive with the files its pattern expanded
+// to.
+type IncludeEdge = crossplane.IncludeEdge
+
+// NewIncludeGraph returns the include graph of a payload parsed without
+// CombineConfigs.
+func NewIncludeGraph(payload *Payload) *IncludeGraph {
+	return crossplane.NewIncludeGraph(payload)
+}
@@ -0,0 +1,36 @@
+// Package crossplane exposes the nginx config AST, parse options and error
+// types so they can be used outside of this module.
+package crossplane
+
+import "github.com/adityals/go-ngx-config/internal/crossplane"
+
+// Payload is the result of parsing an nginx config and all of its includes.
+type Payload = crossplane.Payload
+
+// PayloadError is an error found while parsing any of the payload's configs.
+type PayloadError = crossplane.PayloadError
+
+// Config is a single parsed config file.
+type Config = crossplane.Config
+
+// ConfigError is an error found while parsing a single config file.
+type ConfigError = crossplane.ConfigError
+
+// Directive is a single nginx directive, optionally with a block.
+type Directive = crossplane.Directive
+
+// ParseOptions determine the behavior of an nginx config parse.
+type ParseOptions = crossplane.ParseOptions
+
+// ParseError is the error returned when a config can't be parsed.
+type ParseError = crossplane.ParseError
+
+// Position is a line, column and byte offset in a config file.
+type Position = crossplane.Position
+
+// Range is the part of a config file a directive name or argument was read
+// from.
+type Range = crossplane.Range
+
+// ErrNoConfig is returned when there is no payload to work on.
+var ErrNoConfig = crossplane.ErrNoConfig
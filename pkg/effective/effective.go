@@ -0,0 +1,33 @@
+package effective
+
+import (
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/internal/effective"
+	ngx "github.com/adityals/go-ngx-config/pkg/crossplane"
+	"github.com/adityals/go-ngx-config/pkg/matcher"
+)
+
+// Config is the effective configuration of a matched location.
+type Config = effective.Config
+
+// Level is a block the location inherits directives from.
+type Level = effective.Level
+
+// Setting is the effective value of a directive with the level it came from.
+type Setting = effective.Setting
+
+// Value is a directive found at a level.
+type Value = effective.Value
+
+func NewEffectiveConfig(filename string, targetUrl string, opts *ngx.ParseOptions, matchOpts *matcher.MatchOptions) (*Config, error) {
+	payload, err := crossplane.Parse(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return effective.ForRequest(payload, targetUrl, matchOpts)
+}
+
+func NewEffectiveConfigFromPayload(payload *ngx.Payload, targetUrl string, matchOpts *matcher.MatchOptions) (*Config, error) {
+	return effective.ForRequest(payload, targetUrl, matchOpts)
+}
@@ -0,0 +1,57 @@
+package lint
+
+import (
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/internal/lint"
+	ngx "github.com/adityals/go-ngx-config/pkg/crossplane"
+)
+
+// Rule checks the config for a kind of issue.
+type Rule = lint.Rule
+
+// Finding is an issue a rule found in the config.
+type Finding = lint.Finding
+
+// Severity of a finding.
+type Severity = lint.Severity
+
+const (
+	SeverityError   = lint.SeverityError
+	SeverityWarning = lint.SeverityWarning
+	SeverityInfo    = lint.SeverityInfo
+)
+
+// Linter runs rules on a config.
+type Linter = lint.Linter
+
+// Tree is the config with its includes followed, which rules check.
+type Tree = lint.Tree
+
+// Node is a directive of the tree.
+type Node = lint.Node
+
+// NewLinter returns a linter running the given rules, or the default rules
+// if none is given.
+func NewLinter(rules ...Rule) *Linter {
+	return lint.NewLinter(rules...)
+}
+
+// DefaultRules returns the rules run when no rule is given.
+func DefaultRules() []Rule {
+	return lint.DefaultRules()
+}
+
+func NewNgxConfLinter(filename string, opts *ngx.ParseOptions, rules ...Rule) ([]Finding, error) {
+	payload, err := crossplane.Parse(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return lint.NewLinter(rules...).Lint(payload)
+}
+
+// SecurityRules returns the rules auditing TLS, response headers and access
+// control.
+func SecurityRules() []Rule {
+	return lint.SecurityRules()
+}
@@ -0,0 +1,19 @@
+package lsp
+
+import (
+	"io"
+
+	"github.com/adityals/go-ngx-config/internal/lsp"
+)
+
+// Server is a language server for nginx configs.
+type Server = lsp.Server
+
+// Options of the language server.
+type Options = lsp.Options
+
+// NewLanguageServer returns a language server speaking LSP over in and out,
+// e.g. stdin and stdout.
+func NewLanguageServer(in io.Reader, out io.Writer, options *Options) *Server {
+	return lsp.NewServer(in, out, options)
+}
@@ -5,9 +5,28 @@ import (
 
 	"github.com/adityals/go-ngx-config/internal/crossplane"
 	"github.com/adityals/go-ngx-config/internal/matcher"
+	ngx "github.com/adityals/go-ngx-config/pkg/crossplane"
 )
 
-func NewLocationMatcher(filename string, targetUrl string, opts *crossplane.ParseOptions) (*matcher.LocationMatcher, error) {
+// LocationMatcher is the result of matching a url against the config locations.
+type LocationMatcher = matcher.LocationMatcher
+
+// ServerMatcher is the server block picked to handle the url.
+type ServerMatcher = matcher.ServerMatcher
+
+// LocationHop is a location the request went through.
+type LocationHop = matcher.LocationHop
+
+// MatchOptions determine how internal redirects are followed.
+type MatchOptions = matcher.MatchOptions
+
+// FileSystem tells which files exist when evaluating try_files.
+type FileSystem = matcher.FileSystem
+
+// FileList is a FileSystem made of file paths.
+type FileList = matcher.FileList
+
+func NewLocationMatcher(filename string, targetUrl string, opts *ngx.ParseOptions) (*LocationMatcher, error) {
 	payload, err := crossplane.Parse(filename, opts)
 	if err != nil {
 		return nil, err
@@ -25,7 +44,7 @@ func NewLocationMatcher(filename string, targetUrl string, opts *crossplane.Pars
 	return match, nil
 }
 
-func NewLocationMatcherFromPayload(payload *crossplane.Payload, targetUrl string) (*matcher.LocationMatcher, error) {
+func NewLocationMatcherFromPayload(payload *ngx.Payload, targetUrl string) (*LocationMatcher, error) {
 	match, err := matcher.NewLocationMatcher(payload, targetUrl)
 	if err != nil {
 		return nil, err
@@ -37,3 +56,25 @@ func NewLocationMatcherFromPayload(payload *crossplane.Payload, targetUrl string
 
 	return match, nil
 }
+
+func NewLocationMatcherWithOptions(filename string, targetUrl string, opts *ngx.ParseOptions, matchOpts *MatchOptions) (*LocationMatcher, error) {
+	payload, err := crossplane.Parse(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLocationMatcherFromPayloadWithOptions(payload, targetUrl, matchOpts)
+}
+
+func NewLocationMatcherFromPayloadWithOptions(payload *ngx.Payload, targetUrl string, matchOpts *MatchOptions) (*LocationMatcher, error) {
+	match, err := matcher.NewLocationMatcherWithOptions(payload, targetUrl, matchOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	if match == nil {
+		return nil, errors.New("match is nil")
+	}
+
+	return match, nil
+}
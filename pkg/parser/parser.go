@@ -1,8 +1,13 @@
 package parser
 
-import "github.com/adityals/go-ngx-config/internal/crossplane"
+import (
+	"io"
 
-func NewNgxConfParser(filename string, opts *crossplane.ParseOptions) (*crossplane.Payload, error) {
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+	ngx "github.com/adityals/go-ngx-config/pkg/crossplane"
+)
+
+func NewNgxConfParser(filename string, opts *ngx.ParseOptions) (*ngx.Payload, error) {
 	payload, err := crossplane.Parse(filename, opts)
 	if err != nil {
 		return nil, err
@@ -11,7 +16,7 @@ func NewNgxConfParser(filename string, opts *crossplane.ParseOptions) (*crosspla
 	return payload, nil
 }
 
-func NewNgxConfStringParser(conf string, opts *crossplane.ParseOptions) (*crossplane.Payload, error) {
+func NewNgxConfStringParser(conf string, opts *ngx.ParseOptions) (*ngx.Payload, error) {
 	payload, err := crossplane.ParseString(conf, opts)
 	if err != nil {
 		return nil, err
@@ -19,3 +24,25 @@ func NewNgxConfStringParser(conf string, opts *crossplane.ParseOptions) (*crossp
 
 	return payload, nil
 }
+
+// NewNgxConfFilesParser parses a config tree held in memory, e.g. the files
+// of an uploaded archive, mapping every path to its content.
+func NewNgxConfFilesParser(files map[string]string, entry string, opts *ngx.ParseOptions) (*ngx.Payload, error) {
+	payload, err := crossplane.ParseFiles(files, entry, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// NewNgxConfDumpParser parses the output of nginx -T, splitting it back into
+// the config files it was dumped from.
+func NewNgxConfDumpParser(dump io.Reader, opts *ngx.ParseOptions) (*ngx.Payload, error) {
+	payload, err := crossplane.ParseDump(dump, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,29 @@
+package rewrite
+
+import (
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/internal/rewrite"
+	ngx "github.com/adityals/go-ngx-config/pkg/crossplane"
+)
+
+// Simulation is the outcome of running the rewrite directives for a url.
+type Simulation = rewrite.Simulation
+
+// Step is a rewrite module directive that was run.
+type Step = rewrite.Step
+
+// SimulateOptions describe the simulated request.
+type SimulateOptions = rewrite.SimulateOptions
+
+func NewRewriteSimulator(filename string, targetUrl string, opts *ngx.ParseOptions, simOpts *SimulateOptions) (*Simulation, error) {
+	payload, err := crossplane.Parse(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return rewrite.Simulate(payload, targetUrl, simOpts)
+}
+
+func NewRewriteSimulatorFromPayload(payload *ngx.Payload, targetUrl string, simOpts *SimulateOptions) (*Simulation, error) {
+	return rewrite.Simulate(payload, targetUrl, simOpts)
+}
@@ -0,0 +1,29 @@
+package sarif
+
+import (
+	"github.com/adityals/go-ngx-config/internal/sarif"
+	ngx "github.com/adityals/go-ngx-config/pkg/crossplane"
+	"github.com/adityals/go-ngx-config/pkg/lint"
+)
+
+// Log is a SARIF 2.1.0 log, ready to be uploaded to code scanning.
+type Log = sarif.Log
+
+// NewLog returns an empty log for the given version of the tool.
+func NewLog(version string) *Log {
+	return sarif.NewLog(version)
+}
+
+// NewLintLog returns a log of the findings of a lint run.
+func NewLintLog(version string, rules []lint.Rule, findings []lint.Finding) *Log {
+	log := sarif.NewLog(version)
+	log.AddFindings(rules, findings)
+	return log
+}
+
+// NewParseLog returns a log of the errors found while parsing a payload.
+func NewParseLog(version string, payload *ngx.Payload) *Log {
+	log := sarif.NewLog(version)
+	log.AddPayloadErrors(payload)
+	return log
+}
@@ -0,0 +1,36 @@
+package upstream
+
+import (
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/internal/upstream"
+	ngx "github.com/adityals/go-ngx-config/pkg/crossplane"
+	"github.com/adityals/go-ngx-config/pkg/rewrite"
+)
+
+// Target is where a location sends the request to.
+type Target = upstream.Target
+
+// Upstream is an upstream block of the http context.
+type Upstream = upstream.Upstream
+
+// Server is a server of an upstream block.
+type Server = upstream.Server
+
+func NewUpstreamResolver(filename string, targetUrl string, opts *ngx.ParseOptions, simOpts *rewrite.SimulateOptions) (*Target, error) {
+	payload, err := crossplane.Parse(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return upstream.Resolve(payload, targetUrl, simOpts)
+}
+
+func NewUpstreamResolverFromPayload(payload *ngx.Payload, targetUrl string, simOpts *rewrite.SimulateOptions) (*Target, error) {
+	return upstream.Resolve(payload, targetUrl, simOpts)
+}
+
+// NewUpstreamResolverFromSimulation finds where the location a rewrite
+// simulation ended in sends the request to.
+func NewUpstreamResolverFromSimulation(sim *rewrite.Simulation) *Target {
+	return upstream.ResolveSimulation(sim)
+}
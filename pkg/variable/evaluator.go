@@ -0,0 +1,48 @@
+package variable
+
+import (
+	"github.com/adityals/go-ngx-config/internal/crossplane"
+	"github.com/adityals/go-ngx-config/internal/variable"
+	ngx "github.com/adityals/go-ngx-config/pkg/crossplane"
+)
+
+// Evaluator resolves nginx variables for a simulated request.
+type Evaluator = variable.Evaluator
+
+// Request is the simulated request variables are resolved against.
+type Request = variable.Request
+
+// Options describe the simulated request.
+type Options = variable.Options
+
+// NewVariableEvaluator returns an evaluator for the location handling the
+// url, with the set, map and geo directives of the config applied.
+func NewVariableEvaluator(filename string, targetUrl string, opts *ngx.ParseOptions, evalOpts *Options) (*Evaluator, error) {
+	payload, err := crossplane.Parse(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return variable.ForRequest(payload, targetUrl, evalOpts)
+}
+
+func NewVariableEvaluatorFromPayload(payload *ngx.Payload, targetUrl string, evalOpts *Options) (*Evaluator, error) {
+	return variable.ForRequest(payload, targetUrl, evalOpts)
+}
+
+// Map is a "map $source $variable" block.
+type Map = variable.Map
+
+// MapMatch is the outcome of evaluating a map for a source value.
+type MapMatch = variable.MapMatch
+
+// EvaluateMap returns the value a map directive gives for a source value.
+func EvaluateMap(d ngx.Directive, source string) (*MapMatch, error) {
+	m, err := variable.NewMap(d)
+	if err != nil {
+		return nil, err
+	}
+
+	match := m.Evaluate(source)
+	return &match, nil
+}